@@ -0,0 +1,298 @@
+// @Title       appeal.go
+// @Description 放置考勤申诉(成员对缺勤或迟到提出申诉,组织者处理,通过后手动修改考勤)的网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// AttendanceAppeal 考勤申诉gorm对象,成员认为某次签到的考勤有误时提交
+type AttendanceAppeal struct {
+	ID        uint
+	UserID    uint `gorm:"index"`
+	MeetingID uint `gorm:"index"`
+	SignID    uint
+	// PreviousStatus 提交申诉时的考勤状态
+	PreviousStatus string
+	// Evidence 成员提供的说明及证据
+	Evidence string
+	// Status 申诉状态 pending/approved/rejected/canceled
+	Status     string `gorm:"index;default:pending"`
+	ReviewerID uint
+	// ResolvedStatus 通过时修改后的考勤状态
+	ResolvedStatus string
+	ReviewReason   string
+	CreatedAt      int64
+	ReviewedAt     int64
+}
+
+// appealableStatuses 可以申诉的考勤状态
+var appealableStatuses = map[string]bool{AttendanceAbsent: true, AttendanceLate: true}
+
+// @title         Appeal_attendance
+// @description   成员对自己在某次签到中的缺勤或迟到提出申诉,同一次签到同时只能有一个待处理的申诉,需要先经过GroupMiddleware(CapSignIn)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Appeal_attendance(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			Evidence  string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		request.Evidence = strings.TrimSpace(request.Evidence)
+		if request.Evidence == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "请填写申诉说明"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		userID := _GetContextUserID(c)
+		status, err := _SignAttendanceStatus(GroupDatabase, MeetingDatabase, meeting, userID, sign.ID, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if !appealableStatuses[status] {
+			c.JSON(400, gin.H{"code": 29, "message": fmt.Sprintf("当前考勤为%s,不需要申诉", status)})
+			return
+		}
+		var count int64
+		if err := GroupDatabase.Model(&AttendanceAppeal{}).
+			Where("user_id = ? AND meeting_id = ? AND sign_id = ? AND status = ?", userID, meeting.ID, sign.ID, RequestStatusPending).
+			Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if count > 0 {
+			c.JSON(400, gin.H{"code": 28, "message": "已有待处理的申诉"})
+			return
+		}
+
+		appeal := AttendanceAppeal{
+			UserID:         userID,
+			MeetingID:      meeting.ID,
+			SignID:         sign.ID,
+			PreviousStatus: status,
+			Evidence:       request.Evidence,
+			Status:         RequestStatusPending,
+			CreatedAt:      time.Now().Unix(),
+		}
+		if err := GroupDatabase.Create(&appeal).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已提交申诉", "data": appeal})
+	}
+}
+
+// @title         My_attendance_appeals
+// @description   列出自己在组织内提交的考勤申诉,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_attendance_appeals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var appeals []AttendanceAppeal
+		if err := _GetContextGroupDatabase(c).Where("user_id = ?", _GetContextUserID(c)).Order("id DESC").Find(&appeals).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取申诉成功", "data": appeals})
+	}
+}
+
+// @title         Cancel_attendance_appeal
+// @description   撤回自己尚未处理的考勤申诉,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Cancel_attendance_appeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+
+		var appeal AttendanceAppeal
+		if err := GroupDatabase.Where("id = ? AND user_id = ?", request.ID, _GetContextUserID(c)).First(&appeal).Error; err != nil {
+			c.JSON(400, gin.H{"code": 26, "message": "申诉不存在"})
+			return
+		}
+		result := GroupDatabase.Model(&AttendanceAppeal{}).
+			Where("id = ? AND status = ?", appeal.ID, RequestStatusPending).
+			Update("status", RequestStatusCanceled)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 27, "message": "申诉已被处理或撤回"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已撤回申诉"})
+	}
+}
+
+// @title         Attendance_appeals
+// @description   组织者列出组织内的考勤申诉,需要先经过GroupMiddleware(CapEditAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Attendance_appeals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// Status 为空时列出全部
+			Status string
+			// UserID MeetingID 为0时不筛选
+			UserID    uint
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		query := _GetContextGroupDatabase(c).Order("id DESC")
+		if request.Status != "" {
+			query = query.Where("status = ?", request.Status)
+		}
+		if request.UserID != 0 {
+			query = query.Where("user_id = ?", request.UserID)
+		}
+		if request.MeetingID != 0 {
+			query = query.Where("meeting_id = ?", request.MeetingID)
+		}
+		var appeals []AttendanceAppeal
+		if err := query.Find(&appeals).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取申诉成功", "data": appeals})
+	}
+}
+
+// @title         Review_attendance_appeal
+// @description   组织者处理考勤申诉,通过时经手动修改考勤的流程更正考勤,驳回时必须填写理由,不能处理自己的申诉,需要先经过GroupMiddleware(CapEditAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Review_attendance_appeal(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID      uint
+			Approve bool
+			// Status 通过时修改后的考勤状态 present(默认)/late/excused
+			Status string
+			Reason string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+		if request.Approve {
+			if request.Status == "" {
+				request.Status = AttendancePresent
+			}
+			if request.Status != AttendancePresent && request.Status != AttendanceLate && request.Status != AttendanceExcused {
+				c.JSON(400, gin.H{"code": 1, "message": "Status只能是present、late或excused"})
+				return
+			}
+		} else if request.Reason == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "驳回申诉必须填写理由"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+		reviewerID := _GetContextUserID(c)
+
+		var appeal AttendanceAppeal
+		if err := GroupDatabase.First(&appeal, request.ID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 26, "message": "申诉不存在"})
+			return
+		}
+		if appeal.UserID == reviewerID {
+			c.JSON(403, gin.H{"code": 3, "message": "不能处理自己的申诉"})
+			return
+		}
+
+		updateData := map[string]interface{}{
+			"Status":       RequestStatusRejected,
+			"ReviewerID":   reviewerID,
+			"ReviewReason": request.Reason,
+			"ReviewedAt":   time.Now().Unix(),
+		}
+		if request.Approve {
+			updateData["Status"] = RequestStatusApproved
+			updateData["ResolvedStatus"] = request.Status
+		}
+		result := GroupDatabase.Model(&AttendanceAppeal{}).
+			Where("id = ? AND status = ?", appeal.ID, RequestStatusPending).
+			Updates(updateData)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 27, "message": "申诉已被处理或撤回"})
+			return
+		}
+		if !request.Approve {
+			c.JSON(200, gin.H{"code": 0, "message": "已驳回申诉"})
+			return
+		}
+
+		// restore 更正考勤失败时把申诉恢复为待处理,组织者可以重试
+		restore := func() {
+			GroupDatabase.Model(&AttendanceAppeal{}).Where("id = ?", appeal.ID).
+				Updates(map[string]interface{}{"Status": RequestStatusPending, "ResolvedStatus": ""})
+		}
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, appeal.MeetingID)
+		if !ok {
+			restore()
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		reason := fmt.Sprintf("考勤申诉#%d通过", appeal.ID)
+		if request.Reason != "" {
+			reason += ": " + request.Reason
+		}
+		audit, err := _OverrideAttendance(GroupDatabase, MeetingDatabase, meeting, appeal.UserID, appeal.SignID, request.Status, reason, reviewerID)
+		if err != nil {
+			restore()
+			c.JSON(500, gin.H{"code": 5, "message": "更正考勤失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已通过申诉并更正考勤", "data": audit})
+	}
+}
@@ -0,0 +1,403 @@
+// @Title       attendance.go
+// @Description 放置根据签到记录计算考勤状态(出勤、迟到、缺勤、请假、早退)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 考勤状态
+const (
+	// AttendancePresent 按时签到
+	AttendancePresent = "present"
+	// AttendanceLate 超过宽限时间才签到,或者错过了前面的签到
+	AttendanceLate = "late"
+	// AttendanceAbsent 没有签到
+	AttendanceAbsent = "absent"
+	// AttendanceExcused 请假
+	AttendanceExcused = "excused"
+	// AttendanceLeftEarly 签到后错过了后面的签到
+	AttendanceLeftEarly = "left_early"
+	// AttendancePending 签到尚未结束,还不能确定
+	AttendancePending = "pending"
+)
+
+// 多次签到合并为会议考勤的规则
+const (
+	// AttendanceRuleAll 每次签到都要参加,错过最后的签到算早退,错过中间或前面的签到算迟到
+	AttendanceRuleAll = "all"
+	// AttendanceRuleAny 参加任意一次签到即可,取最好的一次
+	AttendanceRuleAny = "any"
+)
+
+// 迟到宽限时间的参数
+var (
+	// AttendanceDefaultLateGrace 签到开始后多少分钟内签到不算迟到
+	AttendanceDefaultLateGrace = 5
+	// AttendanceMaxLateGrace 宽限时间最多可以设置为多少分钟
+	AttendanceMaxLateGrace = 120
+)
+
+// SignAttendance 成员在一次签到中的考勤
+type SignAttendance struct {
+	SignID uint
+	Status string
+	// SignedAt 签到时间,没有签到或者是记录签到时间之前的签到时为0
+	SignedAt int64
+	// Overridden 考勤状态是否由组织者手动修改
+	Overridden bool
+}
+
+// MemberAttendance 成员在一次会议中的考勤
+type MemberAttendance struct {
+	UserID uint
+	Status string
+	// Signs 按签到开始时间排列的每次签到的考勤
+	Signs []SignAttendance
+}
+
+// @title         classifySign
+// @description   计算成员在一次签到中的考勤状态
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         sign             Sign                "签到"
+// @param         book             *SignatureBook      "成员的签到记录,没有签到时为nil"
+// @param         Grace            time.Duration       "迟到宽限时间"
+// @param         Excused          bool                "成员是否请假"
+// @param         Now              time.Time           "当前时间"
+// @return        status           string              "考勤状态"
+func classifySign(sign Sign, book *SignatureBook, Grace time.Duration, Excused bool, Now time.Time) string {
+	status := AttendancePresent
+	if book == nil {
+		if sign.Status(Now) != SignStatusClosed {
+			return AttendancePending
+		}
+		status = AttendanceAbsent
+	} else if book.SignedAt != 0 && time.Unix(book.SignedAt, 0).After(sign.BeginAt.Add(Grace)) {
+		status = AttendanceLate
+	}
+	if Excused && status != AttendancePresent {
+		return AttendanceExcused
+	}
+	return status
+}
+
+// @title         combineAttendance
+// @description   按规则把成员在每次签到中的考勤合并为会议的考勤,尚未结束及请假的签到不参与计算
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Rule             string              "合并规则 all/any"
+// @param         Statuses         []string            "按签到开始时间排列的每次签到的考勤"
+// @param         Excused          bool                "成员是否请假"
+// @return        status           string              "会议的考勤状态"
+func combineAttendance(Rule string, Statuses []string, Excused bool) string {
+	decided := make([]string, 0, len(Statuses))
+	for _, status := range Statuses {
+		if status == AttendanceExcused {
+			Excused = true
+		} else if status != AttendancePending {
+			decided = append(decided, status)
+		}
+	}
+	if len(decided) == 0 {
+		if Excused {
+			return AttendanceExcused
+		}
+		return AttendancePending
+	}
+
+	attended := func(status string) bool { return status == AttendancePresent || status == AttendanceLate }
+	status := AttendanceAbsent
+	switch Rule {
+	case AttendanceRuleAny:
+		for _, item := range decided {
+			if item == AttendancePresent {
+				status = AttendancePresent
+				break
+			}
+			if item == AttendanceLate {
+				status = AttendanceLate
+			}
+		}
+	default:
+		last := -1
+		for i, item := range decided {
+			if attended(item) {
+				last = i
+			}
+		}
+		if last == -1 {
+			break
+		}
+		status = AttendancePresent
+		if last < len(decided)-1 {
+			status = AttendanceLeftEarly
+			break
+		}
+		for _, item := range decided {
+			if item != AttendancePresent {
+				status = AttendanceLate
+			}
+		}
+	}
+	if Excused && status != AttendancePresent {
+		return AttendanceExcused
+	}
+	return status
+}
+
+// @title         _ExpectedAttendees
+// @description   列出应到的成员:会议期间在组织中(包括之后退出的)的成员,会议登记了参会人时只保留参会人,都只保留拥有sign_in权限且没有roll_call权限的成员
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         meeting                               MeetingInfo         "会议"
+// @return        attendees                             []uint              "按用户ID升序的应到成员"
+// @return        err                                   error               "可能存在的错误"
+func _ExpectedAttendees(GroupDatabase *gorm.DB, MeetingDatabase *gorm.DB, meeting MeetingInfo) ([]uint, error) {
+	var members []MemberInfo
+	if err := GroupDatabase.Where("joined_at < ?", meeting.EndAt.Unix()).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	var histories []MemberHistory
+	if err := GroupDatabase.Where("joined_at < ? AND left_at > ?", meeting.EndAt.Unix(), meeting.BeginAt.Unix()).Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	for _, history := range histories {
+		members = append(members, MemberInfo{UserID: history.UserID, Permissions: history.Permissions})
+	}
+	var participantIDs []uint
+	if err := MeetingDatabase.Model(&MettingParticipants{}).Pluck("user_id", &participantIDs).Error; err != nil {
+		return nil, err
+	}
+	participants := make(map[uint]bool)
+	for _, userID := range participantIDs {
+		participants[userID] = true
+	}
+
+	attendees := []uint{}
+	seen := make(map[uint]bool)
+	for _, member := range members {
+		if seen[member.UserID] || len(participants) > 0 && !participants[member.UserID] {
+			continue
+		}
+		permissions, err := ParseGroupPermissions(member.Permissions)
+		if err != nil || !permissions.Has(CapSignIn) || permissions.Has(CapRollCall) {
+			continue
+		}
+		seen[member.UserID] = true
+		attendees = append(attendees, member.UserID)
+	}
+	sort.Slice(attendees, func(i, j int) bool { return attendees[i] < attendees[j] })
+	return attendees, nil
+}
+
+// @title         _MeetingAttendance
+// @description   计算会议中每个成员的考勤,包括应到成员及其他签到了或被手动修改过考勤的用户,手动修改的状态优先
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         meeting                               MeetingInfo         "会议"
+// @param         Excused                               map[uint]bool       "请假的成员"
+// @param         Now                                   time.Time           "当前时间"
+// @return        attendance                            []MemberAttendance  "按用户ID升序的考勤"
+// @return        err                                   error               "可能存在的错误"
+func _MeetingAttendance(GroupDatabase *gorm.DB, MeetingDatabase *gorm.DB, meeting MeetingInfo, Excused map[uint]bool, Now time.Time) ([]MemberAttendance, error) {
+	setting, err := _GetGroupSetting(GroupDatabase)
+	if err != nil {
+		return nil, err
+	}
+	attendees, err := _ExpectedAttendees(GroupDatabase, MeetingDatabase, meeting)
+	if err != nil {
+		return nil, err
+	}
+	var signs []Sign
+	if err := MeetingDatabase.Where("begin_at <= ?", Now).Order("begin_at ASC").Find(&signs).Error; err != nil {
+		return nil, err
+	}
+	var books []SignatureBook
+	if err := MeetingDatabase.Find(&books).Error; err != nil {
+		return nil, err
+	}
+	var overrides []AttendanceOverride
+	if err := MeetingDatabase.Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	// signed[UserID][SignID] = 签到记录
+	signed := make(map[uint]map[uint]*SignatureBook)
+	for i := range books {
+		if signed[books[i].UserID] == nil {
+			signed[books[i].UserID] = make(map[uint]*SignatureBook)
+		}
+		signed[books[i].UserID][books[i].SignID] = &books[i]
+	}
+	// overridden[UserID][SignID] = 手动修改的考勤状态
+	overridden := make(map[uint]map[uint]string)
+	for _, override := range overrides {
+		if overridden[override.UserID] == nil {
+			overridden[override.UserID] = make(map[uint]string)
+		}
+		overridden[override.UserID][override.SignID] = override.Status
+	}
+	userIDs := append([]uint{}, attendees...)
+	listed := make(map[uint]bool)
+	for _, userID := range attendees {
+		listed[userID] = true
+	}
+	for userID := range signed {
+		if !listed[userID] {
+			listed[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	for userID := range overridden {
+		if !listed[userID] {
+			listed[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	grace := time.Duration(setting.LateGraceMinutes) * time.Minute
+	attendance := make([]MemberAttendance, 0, len(userIDs))
+	for _, userID := range userIDs {
+		member := MemberAttendance{UserID: userID, Signs: make([]SignAttendance, 0, len(signs))}
+		statuses := make([]string, 0, len(signs))
+		for _, sign := range signs {
+			book := signed[userID][sign.ID]
+			item := SignAttendance{SignID: sign.ID, Status: classifySign(sign, book, grace, Excused[userID], Now)}
+			if book != nil {
+				item.SignedAt = book.SignedAt
+			}
+			if status, ok := overridden[userID][sign.ID]; ok {
+				item.Status = status
+				item.Overridden = true
+			}
+			member.Signs = append(member.Signs, item)
+			statuses = append(statuses, item.Status)
+		}
+		member.Status = combineAttendance(setting.AttendanceRule, statuses, Excused[userID])
+		attendance = append(attendance, member)
+	}
+	return attendance, nil
+}
+
+// @title         Attendance
+// @description   列出会议中每个成员的考勤及各状态的人数,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Attendance(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		excused, err := _ExcusedUserIDs(GroupDatabase, meeting)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		attendance, err := _MeetingAttendance(GroupDatabase, MeetingDatabase, meeting, excused, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		userIDs := make([]uint, 0, len(attendance))
+		for _, member := range attendance {
+			userIDs = append(userIDs, member.UserID)
+		}
+		var users []UserInfo
+		if len(userIDs) > 0 {
+			if err := GlobalDatabase.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+		}
+		userMap := make(map[uint]UserInfo)
+		for _, user := range users {
+			userMap[user.ID] = user
+		}
+
+		summary := map[string]int{
+			AttendancePresent: 0, AttendanceLate: 0, AttendanceAbsent: 0,
+			AttendanceExcused: 0, AttendanceLeftEarly: 0, AttendancePending: 0,
+		}
+		data := make([]gin.H, 0, len(attendance))
+		for _, member := range attendance {
+			user := userMap[member.UserID]
+			summary[member.Status]++
+			data = append(data, gin.H{
+				"UserID":   member.UserID,
+				"Name":     user.Name,
+				"NickName": user.NickName,
+				"Status":   member.Status,
+				"Signs":    member.Signs,
+			})
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取考勤成功", "data": data, "Summary": summary})
+	}
+}
+
+// @title         My_attendance
+// @description   查看自己在会议中的考勤,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_attendance(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		excused, err := _ExcusedUserIDs(GroupDatabase, meeting)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		attendance, err := _MeetingAttendance(GroupDatabase, MeetingDatabase, meeting, excused, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		userID := _GetContextUserID(c)
+		for _, member := range attendance {
+			if member.UserID == userID {
+				c.JSON(200, gin.H{"code": 0, "message": "获取考勤成功", "data": member})
+				return
+			}
+		}
+		// 不是应到成员也没有签到过
+		c.JSON(200, gin.H{"code": 0, "message": "获取考勤成功", "data": nil})
+	}
+}
@@ -0,0 +1,233 @@
+// @Title       claim.go
+// @Description 放置学生用组织者生成的一次性认领码认领导入名单时创建的占位用户的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 认领码的参数
+var (
+	// ClaimCodeLength 认领码的字符数
+	ClaimCodeLength = 8
+	// ClaimCodeTTL 认领码的有效期
+	ClaimCodeTTL = 7 * 24 * time.Hour
+	// ClaimCodeMaxAttempts 认领码最多可以输错的次数,超过后必须重新生成
+	ClaimCodeMaxAttempts = 5
+)
+
+// claimCodeAlphabet 认领码使用的字符,去掉了容易混淆的0、O、1、I
+const claimCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// PlaceholderClaim 占位用户的认领码gorm对象,每个占位用户同时只有一个有效的认领码
+type PlaceholderClaim struct {
+	// UserID 占位用户的ID
+	UserID uint `gorm:"primaryKey;autoIncrement:false"`
+	// CodeHash 数据库中只存放认领码的sha256
+	CodeHash string `json:"-"`
+	// GroupID IssuedBy 生成认领码的组织及组织者
+	GroupID   uint
+	IssuedBy  uint
+	Failures  int
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+// @title         hashClaimCode
+// @description   计算认领码的sha256,忽略大小写及空白
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Code             string              "认领码"
+// @return        hash             string              "十六进制sha256"
+func hashClaimCode(Code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.Join(strings.Fields(Code), ""))))
+	return hex.EncodeToString(sum[:])
+}
+
+// @title         _IssuerManagesPlaceholder
+// @description   检查认领码的生成者在占位用户所在的每个组织中是否都有manage_members权限,防止组织者把其它组织的占位用户导入自己的组织后认领
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         PlaceholderID                         uint                "占位用户ID"
+// @param         IssuerID                              uint                "生成认领码的组织者ID"
+// @return        ok                                    bool                "是否都有权限"
+// @return        err                                   error               "可能存在的错误"
+func _IssuerManagesPlaceholder(GlobalDatabase *gorm.DB, GlobalPath string, PlaceholderID uint, IssuerID uint) (bool, error) {
+	UserDatabase, err := InitUser(GlobalPath, PlaceholderID, true)
+	if err != nil {
+		return false, err
+	}
+	defer _CloseDatabase(UserDatabase)
+	var memberOfs []MemberOf
+	if err := UserDatabase.Find(&memberOfs).Error; err != nil {
+		return false, err
+	}
+	for _, memberOf := range memberOfs {
+		if _, err := _GetGroupInfo(GlobalDatabase, memberOf.GroupID); err != nil {
+			// 组织已被删除,等待sync-permissions清理
+			continue
+		}
+		GroupDatabase, err := InitGroup(GlobalPath, memberOf.GroupID, true)
+		if err != nil {
+			return false, err
+		}
+		permissions, err := _GetMemberPermissions(GroupDatabase, IssuerID)
+		_CloseDatabase(GroupDatabase)
+		if err != nil || !permissions.Has(CapManageMembers) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// @title         Issue_claim_code
+// @description   组织者为组织内的占位用户生成一次性认领码,交给对应的学生认领,重新生成后旧的认领码失效,占位用户所在的每个组织都必须由该组织者管理,需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Issue_claim_code(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			UserID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.UserID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if _, err := _GetMemberPermissions(_GetContextGroupDatabase(c), request.UserID); err != nil {
+			c.JSON(400, gin.H{"code": 24, "message": "该用户不是组织成员"})
+			return
+		}
+		var user UserInfo
+		if err := GlobalDatabase.First(&user, request.UserID).Error; err != nil || !user.Placeholder {
+			c.JSON(400, gin.H{"code": 31, "message": "该用户不是待认领的占位用户"})
+			return
+		}
+		ok, err := _IssuerManagesPlaceholder(GlobalDatabase, GlobalPath, user.ID, _GetContextUserID(c))
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if !ok {
+			c.JSON(400, gin.H{"code": 36, "message": "该占位用户还属于你无权管理成员的组织"})
+			return
+		}
+
+		code := randomString(claimCodeAlphabet, ClaimCodeLength)
+		now := time.Now()
+		claim := PlaceholderClaim{
+			UserID:    user.ID,
+			CodeHash:  hashClaimCode(code),
+			GroupID:   _GetContextGroupID(c),
+			IssuedBy:  _GetContextUserID(c),
+			CreatedAt: now.Unix(),
+			ExpiresAt: now.Add(ClaimCodeTTL).Unix(),
+		}
+		if err := GlobalDatabase.Save(&claim).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已生成认领码", "UserID": user.ID, "Name": user.Name,
+			"RegistrationNumber": user.RegistrationNumber, "ClaimCode": code, "ExpiresAt": claim.ExpiresAt})
+	}
+}
+
+// @title         Claim_placeholder
+// @description   学生登陆(微信登陆或注册)后用学号及认领码认领占位用户,占位用户的组织、考勤等记录合并进当前用户,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Claim_placeholder(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			RegistrationNumber string
+			ClaimCode          string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		request.RegistrationNumber = strings.TrimSpace(request.RegistrationNumber)
+		if request.RegistrationNumber == "" || request.ClaimCode == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		userID := _GetContextUserID(c)
+
+		var user UserInfo
+		if err := GlobalDatabase.First(&user, userID).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if user.RegistrationNumber != "" && user.RegistrationNumber != request.RegistrationNumber {
+			c.JSON(400, gin.H{"code": 33, "message": "你的学号与要认领的学号不一致"})
+			return
+		}
+
+		var claim PlaceholderClaim
+		err := GlobalDatabase.Where("expires_at > ?", time.Now().Unix()).
+			Where("user_id IN (?)", GlobalDatabase.Model(&UserInfo{}).Select("id").
+				Where("registration_number = ? AND placeholder = ?", request.RegistrationNumber, true)).
+			First(&claim).Error
+		if err != nil {
+			c.JSON(400, gin.H{"code": 32, "message": "学号或认领码错误"})
+			return
+		}
+		// 比较之前先占用一次机会,并发提交时也不会超过次数限制
+		result := GlobalDatabase.Model(&PlaceholderClaim{}).Where("user_id = ? AND failures < ?", claim.UserID, ClaimCodeMaxAttempts).
+			Update("failures", gorm.Expr("failures + 1"))
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 32, "message": "认领码输错次数过多,请联系组织者重新生成"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(hashClaimCode(request.ClaimCode)), []byte(claim.CodeHash)) != 1 {
+			c.JSON(400, gin.H{"code": 32, "message": "学号或认领码错误"})
+			return
+		}
+		// 生成认领码之后占位用户可能又被导入了其它组织
+		ok, err := _IssuerManagesPlaceholder(GlobalDatabase, GlobalPath, claim.UserID, claim.IssuedBy)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if !ok {
+			c.JSON(400, gin.H{"code": 36, "message": "认领码已失效,请联系组织者重新生成"})
+			return
+		}
+
+		// 先用掉认领码,同一个认领码并发认领时只有一个能成功
+		result = GlobalDatabase.Where("user_id = ? AND code_hash = ?", claim.UserID, claim.CodeHash).Delete(&PlaceholderClaim{})
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 32, "message": "学号或认领码错误"})
+			return
+		}
+		if err := _MergeUser(GlobalDatabase, GlobalPath, claim.UserID, userID); err != nil {
+			// 合并失败时恢复认领码,学生可以重试
+			GlobalDatabase.Create(&claim)
+			c.JSON(500, gin.H{"code": 5, "message": "认领失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "认领成功", "UserID": userID, "PlaceholderID": claim.UserID, "GroupID": claim.GroupID})
+	}
+}
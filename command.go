@@ -0,0 +1,315 @@
+// @Title       command.go
+// @Description 放置管理员命令行子命令(合并用户等),以 ./RollCallApplet <子命令> [参数] 的形式执行
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Command 管理员子命令
+type Command struct {
+	Description string
+	Run         func(args []string) error
+}
+
+// Commands 所有管理员子命令,键为子命令名
+var Commands = map[string]Command{
+	"merge-user": {
+		Description: "把重复的用户合并进保留的用户: merge-user -from <被合并的UserID> -into <保留的UserID>",
+		Run:         commandMergeUser,
+	},
+	"set-admin": {
+		Description: "设置或取消站点管理员: set-admin -user <UserID> [-revoke]",
+		Run:         commandSetAdmin,
+	},
+	"sync-permissions": {
+		Description: "以组织数据库为准修复用户数据库中记录的组织及权限",
+		Run:         commandSyncPermissions,
+	},
+	"list-jwt-keys": {
+		Description: "列出jwt密钥环中的所有密钥(不显示密钥内容)",
+		Run:         commandListJWTKeys,
+	},
+	"rotate-jwt-key": {
+		Description: "生成新的jwt签名密钥,旧密钥在退役前仍可用于校验",
+		Run:         commandRotateJWTKey,
+	},
+	"retire-jwt-key": {
+		Description: "退役jwt密钥,用它签名的Token全部失效: retire-jwt-key -kid <密钥ID>",
+		Run:         commandRetireJWTKey,
+	},
+	"export-attendance": {
+		Description: "导出组织的考勤表: export-attendance -group <GroupID> [-from 2006-01-02] [-to 2006-01-02] [-format csv|xlsx] [-o 文件]",
+		Run:         commandExportAttendance,
+	},
+	"import-roster": {
+		Description: "按学号从csv或xlsx名单导入组织成员: import-roster -group <GroupID> -file <名单> [-dry-run]",
+		Run:         commandImportRoster,
+	},
+}
+
+// @title         RunCommand
+// @description   执行管理员子命令
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         args             []string            "命令行参数(不含程序名)"
+// @return        exitCode         int                 "进程退出码"
+func RunCommand(args []string) int {
+	command, ok := Commands[args[0]]
+	if !ok {
+		printCommandUsage()
+		return 2
+	}
+	if err := command.Run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// printCommandUsage 输出所有子命令的说明
+func printCommandUsage() {
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "可用的子命令:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, Commands[name].Description)
+	}
+}
+
+// commandMergeUser 合并重复的用户
+func commandMergeUser(args []string) error {
+	flags := flag.NewFlagSet("merge-user", flag.ContinueOnError)
+	from := flags.Uint("from", 0, "被合并(删除)的UserID")
+	into := flags.Uint("into", 0, "保留的UserID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == 0 || *into == 0 {
+		flags.Usage()
+		return fmt.Errorf("必须指定-from和-into")
+	}
+	if err := _MergeUser(GlobalDatabase, DataPath, uint(*from), uint(*into)); err != nil {
+		return err
+	}
+	fmt.Printf("已把用户%d合并进用户%d\n", *from, *into)
+	return nil
+}
+
+// commandSetAdmin 设置或取消站点管理员
+func commandSetAdmin(args []string) error {
+	flags := flag.NewFlagSet("set-admin", flag.ContinueOnError)
+	userID := flags.Uint("user", 0, "UserID")
+	revoke := flags.Bool("revoke", false, "取消站点管理员")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userID == 0 {
+		flags.Usage()
+		return fmt.Errorf("必须指定-user")
+	}
+	result := GlobalDatabase.Model(&UserInfo{}).Where("id = ?", *userID).Update("site_admin", !*revoke)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("找不到用户%d", *userID)
+	}
+	if *revoke {
+		fmt.Printf("已取消用户%d的站点管理员权限\n", *userID)
+	} else {
+		fmt.Printf("已把用户%d设为站点管理员\n", *userID)
+	}
+	return nil
+}
+
+// commandSyncPermissions 以组织数据库为准修复用户数据库中的MemberOf
+func commandSyncPermissions(args []string) error {
+	fixed, err := _SyncMemberPermissions(GlobalDatabase, DataPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已修复%d条记录\n", fixed)
+	return nil
+}
+
+// commandListJWTKeys 列出jwt密钥环中的所有密钥
+func commandListJWTKeys(args []string) error {
+	for _, key := range GlobalJWTKeyring.Keys {
+		status := "校验"
+		if key.ID == GlobalJWTKeyring.ActiveKeyID {
+			status = "签名"
+		} else if key.Retired {
+			status = "已退役"
+		}
+		fmt.Printf("%-24s %-8s %s\n", key.ID, status, time.Unix(key.CreatedAt, 0).Format(time.DateTime))
+	}
+	return nil
+}
+
+// commandRotateJWTKey 生成新的jwt签名密钥
+func commandRotateJWTKey(args []string) error {
+	key, err := GlobalJWTKeyring.Rotate()
+	if err != nil {
+		return err
+	}
+	if err := GlobalJWTKeyring.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("新的签名密钥为%s,运行中的服务会在%s内加载\n", key.ID, JWTKeyringReloadInterval)
+	return nil
+}
+
+// commandRetireJWTKey 退役jwt密钥
+func commandRetireJWTKey(args []string) error {
+	flags := flag.NewFlagSet("retire-jwt-key", flag.ContinueOnError)
+	kid := flags.String("kid", "", "需要退役的密钥ID")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *kid == "" {
+		flags.Usage()
+		return fmt.Errorf("必须指定-kid")
+	}
+	if err := GlobalJWTKeyring.Retire(*kid); err != nil {
+		return err
+	}
+	if err := GlobalJWTKeyring.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("已退役密钥%s\n", *kid)
+	return nil
+}
+
+// commandExportAttendance 导出组织的考勤表
+func commandExportAttendance(args []string) error {
+	flags := flag.NewFlagSet("export-attendance", flag.ContinueOnError)
+	groupID := flags.Uint("group", 0, "GroupID")
+	fromFlag := flags.String("from", "", "开始日期(含),默认为结束日期前AttendanceStatsDefaultDays天")
+	toFlag := flags.String("to", "", "结束日期(不含),默认为现在")
+	format := flags.String("format", ExportFormatCSV, "csv或xlsx")
+	output := flags.String("o", "", "输出文件,默认为attendance_<GroupID>_<开始>_<结束>.<格式>")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *groupID == 0 {
+		flags.Usage()
+		return fmt.Errorf("必须指定-group")
+	}
+	if _, ok := exportContentTypes[*format]; !ok {
+		return fmt.Errorf("-format只能是csv或xlsx")
+	}
+	parseDate := func(value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("日期%s的格式应为2006-01-02", value)
+		}
+		return &date, nil
+	}
+	fromDate, err := parseDate(*fromFlag)
+	if err != nil {
+		return err
+	}
+	toDate, err := parseDate(*toFlag)
+	if err != nil {
+		return err
+	}
+	from, to, err := _StatsRange(fromDate, toDate)
+	if err != nil {
+		return err
+	}
+	if _, err := _GetGroupInfo(GlobalDatabase, uint(*groupID)); err != nil {
+		return fmt.Errorf("找不到组织%d", *groupID)
+	}
+
+	GroupDatabase, err := InitGroup(DataPath, uint(*groupID), true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(GroupDatabase)
+	matrix, err := _AttendanceMatrix(DataPath, GroupDatabase, uint(*groupID), from, to)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = _ExportFileName(uint(*groupID), from, to, *format)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := _WriteAttendanceExport(GlobalDatabase, matrix, file, *format); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("已把%d次会议的考勤导出到%s\n", len(matrix.Meetings), *output)
+	return nil
+}
+
+// commandImportRoster 按学号从名单导入组织成员
+func commandImportRoster(args []string) error {
+	flags := flag.NewFlagSet("import-roster", flag.ContinueOnError)
+	groupID := flags.Uint("group", 0, "GroupID")
+	fileName := flags.String("file", "", "csv或xlsx名单,表头必须包含学号列")
+	dryRun := flags.Bool("dry-run", false, "只预览每一行的处理结果,不写入")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *groupID == 0 || *fileName == "" {
+		flags.Usage()
+		return fmt.Errorf("必须指定-group和-file")
+	}
+	content, err := os.ReadFile(*fileName)
+	if err != nil {
+		return err
+	}
+	roster, err := _ReadRoster(*fileName, content)
+	if err != nil {
+		return fmt.Errorf("无法读取名单: %w", err)
+	}
+	if _, err := _GetGroupInfo(GlobalDatabase, uint(*groupID)); err != nil {
+		return fmt.Errorf("找不到组织%d", *groupID)
+	}
+
+	GroupDatabase, err := InitGroup(DataPath, uint(*groupID), true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(GroupDatabase)
+	results, err := _ImportRoster(GlobalDatabase, DataPath, GroupDatabase, uint(*groupID), roster, *dryRun)
+	if err != nil {
+		return err
+	}
+	summary := make(map[string]int)
+	for _, result := range results {
+		summary[result.Action]++
+		if result.Action == RosterActionError {
+			fmt.Printf("第%d行 %s %s: %s\n", result.Row, result.RegistrationNumber, result.Name, result.Error)
+		}
+		if result.Confirm && result.Action == RosterActionAdd {
+			fmt.Printf("第%d行 %s %s: 匹配到已注册的用户%d(%s),请核对\n", result.Row, result.RegistrationNumber, result.Name, result.UserID, result.MatchedName)
+		}
+	}
+	prefix := "已导入"
+	if *dryRun {
+		prefix = "预览"
+	}
+	fmt.Printf("%s: 加入已有用户%d人,新建占位用户%d人,已是成员%d人,错误%d行\n", prefix,
+		summary[RosterActionAdd], summary[RosterActionCreate], summary[RosterActionExists], summary[RosterActionError])
+	return nil
+}
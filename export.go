@@ -0,0 +1,264 @@
+// @Title       export.go
+// @Description 放置把组织一段时间内的考勤导出为 成员×会议 的csv或xlsx表格的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 导出考勤的文件格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// exportContentTypes 每种导出格式的Content-Type
+var exportContentTypes = map[string]string{
+	ExportFormatCSV:  "text/csv; charset=utf-8",
+	ExportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// attendanceStatusLabels 导出表格中考勤状态的显示文字
+var attendanceStatusLabels = map[string]string{
+	AttendancePresent:   "出勤",
+	AttendanceLate:      "迟到",
+	AttendanceAbsent:    "缺勤",
+	AttendanceExcused:   "请假",
+	AttendanceLeftEarly: "早退",
+	AttendancePending:   "未结束",
+}
+
+// sheetWriter 逐行写出表格
+type sheetWriter interface {
+	WriteRow(Cells []string) error
+	Close() error
+}
+
+// csvSheetWriter 用csv写出表格,开头写出BOM以便Excel正确识别UTF-8
+type csvSheetWriter struct {
+	writer *csv.Writer
+}
+
+// csvFormulaPrefixes 以这些字符开头的单元格会被Excel当作公式执行
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// WriteRow 以公式字符开头的单元格前加上',防止用户填写的姓名等被当作公式执行(CSV注入)
+func (writer csvSheetWriter) WriteRow(Cells []string) error {
+	escaped := make([]string, len(Cells))
+	for i, cell := range Cells {
+		if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+			cell = "'" + cell
+		}
+		escaped[i] = cell
+	}
+	return writer.writer.Write(escaped)
+}
+
+func (writer csvSheetWriter) Close() error {
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+// @title         _NewSheetWriter
+// @description   按格式创建表格编写器
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Writer           io.Writer           "输出"
+// @param         Format           string              "csv/xlsx"
+// @return        writer           sheetWriter         "表格编写器,写完后必须Close"
+// @return        err              error               "可能存在的错误"
+func _NewSheetWriter(Writer io.Writer, Format string) (sheetWriter, error) {
+	switch Format {
+	case ExportFormatCSV:
+		if _, err := io.WriteString(Writer, "\ufeff"); err != nil {
+			return nil, err
+		}
+		return csvSheetWriter{writer: csv.NewWriter(Writer)}, nil
+	case ExportFormatXLSX:
+		return NewXLSXWriter(Writer, "考勤")
+	}
+	return nil, fmt.Errorf("不支持的导出格式%s", Format)
+}
+
+// @title         _ExportFileName
+// @description   生成导出文件的文件名
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         GroupID          uint                "组织ID"
+// @param         From             time.Time           "开始时间"
+// @param         To               time.Time           "结束时间"
+// @param         Format           string              "csv/xlsx"
+// @return        name             string              "文件名"
+func _ExportFileName(GroupID uint, From time.Time, To time.Time, Format string) string {
+	return fmt.Sprintf("attendance_%d_%s_%s.%s", GroupID, From.Format("20060102"), To.Format("20060102"), Format)
+}
+
+// attendanceMatrix 导出用的 成员×会议 考勤状态
+type attendanceMatrix struct {
+	// Meetings 按开始时间升序的会议
+	Meetings []MeetingInfo
+	// Statuses 每个成员在每次会议中的考勤文字,不在考勤中的会议为空字符串,末尾没有考勤的会议省略
+	Statuses map[uint][]string
+	// UserIDs 按第一次出现的顺序排列的成员
+	UserIDs []uint
+	Stats   map[uint]*MemberAttendanceStats
+}
+
+// @title         _AttendanceMatrix
+// @description   逐个会议计算考勤,只保留每个成员在每次会议中的考勤状态,不保留每次签到的考勤
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         From                                  time.Time           "开始时间"
+// @param         To                                    time.Time           "结束时间"
+// @return        matrix                                attendanceMatrix    "考勤状态"
+// @return        err                                   error               "可能存在的错误"
+func _AttendanceMatrix(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, From time.Time, To time.Time) (attendanceMatrix, error) {
+	matrix := attendanceMatrix{Statuses: make(map[uint][]string), Stats: make(map[uint]*MemberAttendanceStats)}
+	meetings, err := _EachMeetingAttendance(GlobalPath, GroupDatabase, GroupID, From, To, func(index int, _ MeetingInfo, attendance []MemberAttendance) error {
+		for _, member := range attendance {
+			row, ok := matrix.Statuses[member.UserID]
+			if !ok {
+				matrix.UserIDs = append(matrix.UserIDs, member.UserID)
+			}
+			for len(row) < index {
+				row = append(row, "")
+			}
+			matrix.Statuses[member.UserID] = append(row, attendanceStatusLabels[member.Status])
+			addMemberStatus(matrix.Stats, member.UserID, member.Status)
+		}
+		return nil
+	})
+	matrix.Meetings = meetings
+	return matrix, err
+}
+
+// @title         _WriteAttendanceExport
+// @description   把考勤写成 成员×会议 的表格,每行依次为成员信息、每次会议的考勤及汇总,成员信息从全局数据库逐行读取
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         Matrix                                attendanceMatrix    "考勤状态"
+// @param         Writer                                io.Writer           "输出"
+// @param         Format                                string              "csv/xlsx"
+// @return        err                                   error               "可能存在的错误"
+func _WriteAttendanceExport(GlobalDatabase *gorm.DB, Matrix attendanceMatrix, Writer io.Writer, Format string) error {
+	header := []string{"姓名", "学号", "学院", "专业", "年级"}
+	for _, meeting := range Matrix.Meetings {
+		header = append(header, meeting.BeginAt.Local().Format("2006-01-02 15:04")+" "+meeting.MeetingDescription)
+	}
+	header = append(header, "出勤", "迟到", "缺勤", "请假", "早退", "出勤率")
+
+	sheet, err := _NewSheetWriter(Writer, Format)
+	if err != nil {
+		return err
+	}
+	if err := sheet.WriteRow(header); err != nil {
+		return err
+	}
+	writeMember := func(user UserInfo) error {
+		row := []string{user.Name, user.RegistrationNumber, user.Collage, user.Majar, ""}
+		if user.Grade != 0 {
+			row[4] = strconv.FormatUint(uint64(user.Grade), 10)
+		}
+		row = append(row, Matrix.Statuses[user.ID]...)
+		for len(row) < 5+len(Matrix.Meetings) {
+			row = append(row, "")
+		}
+		item := Matrix.Stats[user.ID]
+		return sheet.WriteRow(append(row,
+			strconv.Itoa(item.Present), strconv.Itoa(item.Late), strconv.Itoa(item.Absent),
+			strconv.Itoa(item.Excused), strconv.Itoa(item.LeftEarly),
+			strconv.FormatFloat(item.Rate*100, 'f', 2, 64)+"%"))
+	}
+
+	if len(Matrix.UserIDs) > 0 {
+		rows, err := GlobalDatabase.Model(&UserInfo{}).Where("id IN ?", Matrix.UserIDs).
+			Order("registration_number ASC").Order("id ASC").Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var user UserInfo
+			if err := GlobalDatabase.ScanRows(rows, &user); err != nil {
+				return err
+			}
+			if err := writeMember(user); err != nil {
+				return err
+			}
+			delete(Matrix.Statuses, user.ID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// 已经注销的用户放在最后
+		for _, userID := range Matrix.UserIDs {
+			if _, ok := Matrix.Statuses[userID]; ok {
+				if err := writeMember(UserInfo{ID: userID, Name: fmt.Sprintf("用户%d", userID)}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return sheet.Close()
+}
+
+// @title         Export_attendance
+// @description   把组织一段时间内的考勤导出为csv或xlsx文件,边生成边返回,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Export_attendance(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			From *time.Time
+			To   *time.Time
+			// Format csv(默认)/xlsx
+			Format string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if request.Format == "" {
+			request.Format = ExportFormatCSV
+		}
+		contentType, ok := exportContentTypes[request.Format]
+		if !ok {
+			c.JSON(400, gin.H{"code": 1, "message": "Format只能是csv或xlsx"})
+			return
+		}
+		from, to, err := _StatsRange(request.From, request.To)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+		groupID := _GetContextGroupID(c)
+		matrix, err := _AttendanceMatrix(GlobalPath, _GetContextGroupDatabase(c), groupID, from, to)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", _ExportFileName(groupID, from, to, request.Format)))
+		c.Status(200)
+		// 已经开始返回文件,出错时只能记录日志
+		if err := _WriteAttendanceExport(GlobalDatabase, matrix, c.Writer, request.Format); err != nil {
+			log.Printf("Failed to export attendance of group %d: %v\n", groupID, err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// @Title       geofence.go
+// @Description 放置签到地理围栏(圆形或多边形)以及距离计算的工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 地理围栏的参数
+var (
+	// GeofenceMaxAccuracy 定位精度(米)比这个值差时拒绝签到
+	GeofenceMaxAccuracy = 200.0
+	// GeofenceMaxSlack 定位精度最多可以抵消多少米的距离,定位精度由客户端上报,不能完全信任
+	GeofenceMaxSlack = 30.0
+	// GeofenceMaxRadius 圆形围栏的最大半径(米)
+	GeofenceMaxRadius = 10000.0
+)
+
+// earthRadius 地球平均半径(米)
+const earthRadius = 6371008.8
+
+// GeoPolygon 多边形围栏的顶点,每个顶点为[纬度, 经度],在数据库中以json文本存储
+type GeoPolygon [][2]float64
+
+// GormDataType 数据库中的类型
+func (polygon GeoPolygon) GormDataType() string {
+	return "text"
+}
+
+// Value 转换为json文本写入数据库
+func (polygon GeoPolygon) Value() (driver.Value, error) {
+	if len(polygon) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(polygon)
+	return string(data), err
+}
+
+// Scan 从数据库读取json文本
+func (polygon *GeoPolygon) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*polygon = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法把%T转换为GeoPolygon", value)
+	}
+	if len(data) == 0 {
+		*polygon = nil
+		return nil
+	}
+	return json.Unmarshal(data, polygon)
+}
+
+// Geofence 签到地理围栏,设置了Polygon时使用多边形,否则Radius大于0时使用以(Latitude, Longitude)为圆心的圆
+type Geofence struct {
+	Latitude  float64
+	Longitude float64
+	// Radius 圆形围栏的半径(米)
+	Radius  float64
+	Polygon GeoPolygon
+}
+
+// Enabled 是否设置了围栏
+func (fence Geofence) Enabled() bool {
+	return len(fence.Polygon) > 0 || fence.Radius > 0
+}
+
+// @title         Validate
+// @description   检查围栏的经纬度、半径及多边形是否合法
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        err              error               "不合法时的错误"
+func (fence Geofence) Validate() error {
+	if len(fence.Polygon) > 0 {
+		if len(fence.Polygon) < 3 {
+			return errors.New("多边形围栏至少需要3个顶点")
+		}
+		for _, vertex := range fence.Polygon {
+			if !validCoordinate(vertex[0], vertex[1]) {
+				return fmt.Errorf("多边形顶点%v的经纬度不合法", vertex)
+			}
+		}
+		return nil
+	}
+	if fence.Radius < 0 || fence.Radius > GeofenceMaxRadius {
+		return fmt.Errorf("围栏半径必须在0到%.0f米之间", GeofenceMaxRadius)
+	}
+	if fence.Radius > 0 && !validCoordinate(fence.Latitude, fence.Longitude) {
+		return errors.New("围栏中心的经纬度不合法")
+	}
+	return nil
+}
+
+// @title         Distance
+// @description   计算点到围栏的距离(米),在围栏内时为0
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Latitude         float64             "纬度"
+// @param         Longitude        float64             "经度"
+// @return        distance         float64             "到围栏的距离(米)"
+func (fence Geofence) Distance(Latitude float64, Longitude float64) float64 {
+	if len(fence.Polygon) > 0 {
+		return polygonDistance(fence.Polygon, Latitude, Longitude)
+	}
+	return math.Max(0, haversineDistance(fence.Latitude, fence.Longitude, Latitude, Longitude)-fence.Radius)
+}
+
+// @title         Contains
+// @description   判断定位结果是否在围栏内,到围栏的距离不超过定位精度(最多GeofenceMaxSlack,圆形围栏时也不超过半径)即视为在围栏内
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Latitude         float64             "纬度"
+// @param         Longitude        float64             "经度"
+// @param         Accuracy         float64             "定位精度(米)"
+// @return        inside           bool                "是否在围栏内"
+// @return        distance         float64             "到围栏的距离(米)"
+func (fence Geofence) Contains(Latitude float64, Longitude float64, Accuracy float64) (bool, float64) {
+	distance := fence.Distance(Latitude, Longitude)
+	slack := math.Min(math.Max(Accuracy, 0), GeofenceMaxSlack)
+	if len(fence.Polygon) == 0 {
+		slack = math.Min(slack, fence.Radius)
+	}
+	return distance <= slack, distance
+}
+
+// validCoordinate 经纬度是否在合法范围内
+func validCoordinate(Latitude float64, Longitude float64) bool {
+	return Latitude >= -90 && Latitude <= 90 && Longitude >= -180 && Longitude <= 180 &&
+		!math.IsNaN(Latitude) && !math.IsNaN(Longitude)
+}
+
+// @title         haversineDistance
+// @description   用haversine公式计算两点间的大圆距离
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Latitude1        float64             "第一个点的纬度"
+// @param         Longitude1       float64             "第一个点的经度"
+// @param         Latitude2        float64             "第二个点的纬度"
+// @param         Longitude2       float64             "第二个点的经度"
+// @return        distance         float64             "距离(米)"
+func haversineDistance(Latitude1 float64, Longitude1 float64, Latitude2 float64, Longitude2 float64) float64 {
+	phi1 := Latitude1 * math.Pi / 180
+	phi2 := Latitude2 * math.Pi / 180
+	deltaPhi := (Latitude2 - Latitude1) * math.Pi / 180
+	deltaLambda := (Longitude2 - Longitude1) * math.Pi / 180
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// @title         polygonDistance
+// @description   计算点到多边形的距离,点在多边形内时为0.建筑物尺度下把经纬度投影到以该点为原点的平面(米)上计算
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         polygon          GeoPolygon          "多边形顶点"
+// @param         Latitude         float64             "纬度"
+// @param         Longitude        float64             "经度"
+// @return        distance         float64             "距离(米)"
+func polygonDistance(polygon GeoPolygon, Latitude float64, Longitude float64) float64 {
+	scaleY := earthRadius * math.Pi / 180
+	scaleX := scaleY * math.Cos(Latitude*math.Pi/180)
+	points := make([][2]float64, len(polygon))
+	for i, vertex := range polygon {
+		points[i] = [2]float64{(vertex[1] - Longitude) * scaleX, (vertex[0] - Latitude) * scaleY}
+	}
+
+	// 射线法判断原点是否在多边形内,同时计算到各条边的最短距离
+	inside := false
+	distance := math.Inf(1)
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a[1] > 0) != (b[1] > 0) && 0 < (b[0]-a[0])*(0-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+		distance = math.Min(distance, segmentDistance(a, b))
+	}
+	if inside {
+		return 0
+	}
+	return distance
+}
+
+// segmentDistance 原点到线段ab的距离
+func segmentDistance(a [2]float64, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(a[0]*dx+a[1]*dy)/length))
+	}
+	return math.Hypot(a[0]+t*dx, a[1]+t*dy)
+}
+
+// @title         geofenceColumns
+// @description   把围栏转换为用于Updates的列,列名带embeddedPrefix
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         fence            Geofence            "围栏"
+// @return        columns          map[string]interface{} "列名到值"
+func geofenceColumns(fence Geofence) map[string]interface{} {
+	return map[string]interface{}{
+		"fence_latitude":  fence.Latitude,
+		"fence_longitude": fence.Longitude,
+		"fence_radius":    fence.Radius,
+		"fence_polygon":   fence.Polygon,
+	}
+}
+
+// @title         signGeofence
+// @description   取签到实际生效的围栏,签到没有设置围栏时使用会议的围栏
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         meeting          MeetingInfo         "会议信息"
+// @param         sign             Sign                "签到"
+// @return        fence            Geofence            "生效的围栏,可能未设置"
+func signGeofence(meeting MeetingInfo, sign Sign) Geofence {
+	if sign.Geofence.Enabled() {
+		return sign.Geofence
+	}
+	return meeting.Geofence
+}
+
+// @title         _CheckSignLocation
+// @description   校验成员上报的位置是否在围栏内,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         fence                                 Geofence            "签到生效的围栏"
+// @param         Latitude                              *float64            "上报的纬度"
+// @param         Longitude                             *float64            "上报的经度"
+// @param         Accuracy                              *float64            "上报的定位精度(米)"
+// @return        ok                                    bool                "是否在围栏内"
+func _CheckSignLocation(c *gin.Context, fence Geofence, Latitude *float64, Longitude *float64, Accuracy *float64) bool {
+	if Latitude == nil || Longitude == nil || !validCoordinate(*Latitude, *Longitude) {
+		c.JSON(400, gin.H{"code": 15, "message": "该签到需要上报位置"})
+		return false
+	}
+	accuracy := 0.0
+	if Accuracy != nil {
+		accuracy = *Accuracy
+	}
+	if accuracy < 0 || accuracy > GeofenceMaxAccuracy {
+		c.JSON(400, gin.H{"code": 16, "message": fmt.Sprintf("定位精度不足,需要在%.0f米以内", GeofenceMaxAccuracy)})
+		return false
+	}
+	if inside, distance := fence.Contains(*Latitude, *Longitude, accuracy); !inside {
+		c.JSON(400, gin.H{"code": 17, "message": "不在签到范围内", "Distance": math.Round(distance)})
+		return false
+	}
+	return true
+}
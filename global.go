@@ -6,11 +6,12 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"math/big"
 	"os"
 	"time"
 
@@ -33,7 +34,13 @@ type UserInfo struct {
 	Majar              string
 	Grade              uint
 	PhoneNumber        string
-	RegistrationNumber string
+	RegistrationNumber string `gorm:"index"`
+	// RegistrationVerified 学号是否经过验证(认领占位用户时核对过),验证后不能自己修改;导入名单时只按占位用户及验证过的学号匹配
+	RegistrationVerified bool `gorm:"index"`
+	// SiteAdmin 站点管理员(审核创建组织的申请等),只能通过set-admin子命令修改
+	SiteAdmin bool
+	// Placeholder 导入名单时创建、还没有被学生认领的用户,没有任何登陆方式
+	Placeholder bool `gorm:"index"`
 }
 
 /*
@@ -42,12 +49,16 @@ type UserInfo struct {
 
 // Login 用户登陆信息gorm对象,定义了用户名密码等用于登陆的信息
 type Login struct {
-	UserID   uint   `gorm:"unique;not null"`
-	Username string `gorm:"unique"`
-	// Password字段可以在后端加盐并hash
-	// 但后端必须保证盐会被长久保存
+	UserID uint `gorm:"unique;not null"`
+	// Username及OpenID为空时存为NULL,避免多个未设置的账号违反唯一约束
+	Username string `gorm:"unique;default:null"`
+	// Password字段存放argon2id加盐哈希(带算法及参数前缀,盐也编码在其中)
+	// 不带前缀的旧明文密码会在启动时迁移,参数变化后会在登陆时重新哈希
 	Password string
-	OpenID   string `gorm:"unique"`
+	OpenID   string `gorm:"unique;default:null"`
+	// 微信登陆时jscode2session返回的会话密钥及UnionID
+	SessionKey string `json:"-"`
+	UnionID    string
 	// 修改login表时撤销所有token即可 无需为此添加UpdateAt字段
 	// UpdateAt int64
 }
@@ -57,11 +68,23 @@ type Login struct {
 如果要每个用户一个Token数据库则需要在前端无法获取到UserID时要求重新登陆
 */
 
-// Token 令牌gorm对象,定义了用户的Token,用于操作时(比如加入组织等)鉴权
+// Token 令牌gorm对象,每一行是一个登陆会话,Token字段为短期的access token(jwt),用于操作时(比如加入组织等)鉴权
+// access token过期后用refresh token换取新的access token,refresh token每次使用后都会轮换
 type Token struct {
-	UserID    uint
-	Token     string `gorm:"unique"`
+	ID     uint   `gorm:"primaryKey;AUTO_INCREMENT"`
+	UserID uint   `gorm:"index"`
+	Token  string `gorm:"unique"`
+	// RefreshToken 只存放refresh token的sha256
+	RefreshToken string `gorm:"unique;default:null"`
+	// PreviousRefreshToken 上一个refresh token的sha256,再次出现时说明refresh token被盗用
+	PreviousRefreshToken string `gorm:"index"`
+	// Device 登陆时的设备描述(请求中的Device或User-Agent)
+	Device    string
 	CreatedAt int64
+	// 以下时间均为unix秒
+	LastUsedAt       int64
+	ExpiresAt        int64
+	RefreshExpiresAt int64 `gorm:"index"`
 }
 
 // WXLoginResp 微信登陆返回值json对象,用于接收微信登陆函数的返回值,(不重要)
@@ -75,18 +98,40 @@ type WXLoginResp struct {
 
 // GroupInfo 部门信息gorm对象,记录了最基础的部门id和部门Code的对应关系
 type GroupInfo struct {
-	ID        uint
-	GroupCode string `gorm:"unique"`
+	ID               uint
+	GroupCode        string `gorm:"unique"`
+	GroupName        string
+	GroupDescription string
+	// OwnerID 创建者(申请人)的UserID
+	OwnerID   uint
+	CreatedAt int64
 }
 
+// 各类申请(创建组织/加入组织/请假/申诉等)的状态
+const (
+	RequestStatusPending  = "pending"
+	RequestStatusApproved = "approved"
+	RequestStatusRejected = "rejected"
+	// RequestStatusCanceled 申请人在审核前撤回
+	RequestStatusCanceled = "canceled"
+)
+
 // CreateGroupRequest 创建部门请求gorm对象,记录了创建部门的申请
 type CreateGroupRequest struct {
 	ID               uint
-	UserID           uint
+	UserID           uint `gorm:"index"`
 	Reason           string
 	GroupName        string
 	GroupCode        string `gorm:"unique"`
 	GroupDescription string
+	// Status 申请状态 pending/approved/rejected
+	Status string `gorm:"index;default:pending"`
+	// 审核人、审核意见以及审核通过后创建的组织ID
+	ReviewerID   uint
+	ReviewReason string
+	GroupID      uint
+	CreatedAt    int64
+	ReviewedAt   int64
 }
 
 // @title         InitGlobal
@@ -119,11 +164,21 @@ func InitGlobal(GlobalPath string, SafeMode bool) (*gorm.DB, error) {
 
 	}
 	if SafeMode {
+		// 旧版Token表没有主键,sqlite无法直接添加,直接重建(所有用户需要重新登陆)
+		if GlobalDatabase.Migrator().HasTable(&Token{}) && !GlobalDatabase.Migrator().HasColumn(&Token{}, "id") {
+			if err := GlobalDatabase.Migrator().DropTable(&Token{}); err != nil {
+				return nil, errors.New("failed to drop legacy token table")
+			}
+		}
 		// AutoMigrate 自动迁移数据库
-		err = GlobalDatabase.AutoMigrate(&UserInfo{}, &Login{}, &Token{}, &CreateGroupRequest{}, &GroupInfo{})
+		err = GlobalDatabase.AutoMigrate(&UserInfo{}, &Login{}, &Token{}, &CreateGroupRequest{}, &GroupInfo{}, &PlaceholderClaim{})
 		if err != nil {
 			return nil, errors.New("failed to AutoMigrate database")
 		}
+		// 迁移旧的明文密码
+		if _, err = _MigratePlaintextPasswords(GlobalDatabase); err != nil {
+			return nil, errors.New("failed to migrate plaintext passwords")
+		}
 	}
 	return GlobalDatabase, nil
 }
@@ -132,33 +187,79 @@ func InitGlobal(GlobalPath string, SafeMode bool) (*gorm.DB, error) {
 // @description   生成token的函数
 // @auth          DataEraserC              (2024/2/17   21:54)
 // @param         UserID           uint                "指定需要生成token的UserID"
-// @param         secretKey        string              "指定用于生成token的密钥"
+// @param         keyring          *JWTKeyring         "密钥环,使用其中的签名密钥并在头中写入kid"
+// @param         expiresAt        time.Time           "Token的过期时间"
 // @return        tokenString      string              "令牌字符串"
-func generateToken(UserID uint, secretKey string) string {
+// @return        err              error               "可能存在的错误"
+func generateToken(UserID uint, keyring *JWTKeyring, expiresAt time.Time) (string, error) {
+	key, err := keyring.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+
 	// 创建一个Token对象
 	token := jwt.New(jwt.SigningMethodHS256)
+	token.Header["kid"] = key.ID
 
 	// 设置Token的自定义声明
 	claims := token.Claims.(jwt.MapClaims)
 	claims["userid"] = UserID
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix() // 设置Token的过期时间
+	claims["exp"] = expiresAt.Unix() // 设置Token的过期时间
+	// 同一秒内多次登陆时userid和exp完全相同,需要随机的jti避免生成重复的Token
+	claims["jti"] = randomHex(16)
 
 	// 使用密钥对Token进行签名，生成最终的Token字符串
-	tokenString, _ := token.SignedString([]byte(secretKey))
-	return tokenString
+	return token.SignedString([]byte(key.Secret))
+}
+
+// @title         randomHex
+// @description   生成密码学安全的随机十六进制字符串
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         n                int                 "随机字节数(返回的字符串长度为2n)"
+// @return        hexString        string              "随机十六进制字符串"
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand读取失败说明系统熵源不可用,无法安全地继续运行
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// @title         randomString
+// @description   从给定的字符中逐个随机选取,生成密码学安全的随机字符串
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         alphabet         string              "可以使用的字符(只支持单字节字符)"
+// @param         n                int                 "字符串长度"
+// @return        randomString     string              "随机字符串"
+func randomString(alphabet string, n int) string {
+	buf := make([]byte, n)
+	for i := range buf {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			panic(err)
+		}
+		buf[i] = alphabet[index.Int64()]
+	}
+	return string(buf)
 }
 
 // @title         parseToken
 // @description   解析token的函数
 // @auth          DataEraserC              (2024/2/17   21:54)
 // @param         tokenString      string              "指定需要解析的Token"
-// @param         secretKey        string              "指定用于解析token的密钥"
+// @param         keyring          *JWTKeyring         "密钥环,按Token头中的kid选择校验密钥"
 // @return        claims           jwt.MapClaims       "解析获得的声明对象键值对"
 // @return        err              error               "可能存在的错误"
-func parseToken(tokenString string, secretKey string) (jwt.MapClaims, error) {
+func parseToken(tokenString string, keyring *JWTKeyring) (jwt.MapClaims, error) {
 	// 解析Token字符串
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
+		kid, _ := token.Header["kid"].(string)
+		key, err := keyring.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(key.Secret), nil
 	})
 
 	if err != nil {
@@ -188,6 +289,7 @@ func Login_account_password(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 		var request struct {
 			Username string `json:"Username"`
 			Password string `json:"Password"`
+			Device   string `json:"Device"`
 		}
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
@@ -200,124 +302,108 @@ func Login_account_password(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		if login.Password != request.Password {
+		match, needRehash, err := verifyPassword(login.Password, request.Password)
+		if err != nil || !match {
 			c.JSON(400, gin.H{"code": 2, "message": "用户名或密码错误"})
 			return
 		}
 
-		token := Token{
-			UserID: login.UserID,
-			Token:  generateToken(login.UserID, JWTSecretKey),
+		// 哈希参数变化(或仍为明文)时用当前参数重新哈希,失败不影响本次登陆
+		if needRehash {
+			if hashed, err := hashPassword(request.Password); err == nil {
+				if err := GlobalDatabase.Model(&Login{}).Where("user_id = ?", login.UserID).Update("password", hashed).Error; err != nil {
+					log.Printf("Failed to rehash password of user %d: %v\n", login.UserID, err)
+				}
+			}
 		}
-		if err := GlobalDatabase.Create(&token).Error; err != nil {
+
+		token, refreshToken, err := _IssueSession(GlobalDatabase, login.UserID, _GetDevice(c, request.Device))
+		if err != nil {
 			c.JSON(400, gin.H{"code": 4, "message": "无法生成token"})
 			return
 		}
 
-		c.JSON(200, gin.H{"code": 0, "message": "登录成功", "Token": token.Token, "UserID": token.UserID})
+		c.JSON(200, _SessionResponse("登录成功", token, refreshToken))
 	}
 }
 
-// unfinished
-
 // @title         Login_wx
-// @description   处理微信登陆入口的函数
+// @description   处理微信登陆入口的函数,OpenID未注册时在同一事务中创建UserInfo及Login
 // @auth          DataEraserC                    (2024/2/17   21:54)
 // @param         GlobalDatabase         *gorm.DB            "全局数据库"
-// @param         WXAppID                string              "微信小程序AppID"
-// @param         WXAppSecret            string              "微信小程序AppSecret"
+// @param         WXClient               WXClient            "微信接口客户端"
 // @return        匿名函数               gin.HandlerFunc     "gin消息中间件"
-func Login_wx(GlobalDatabase *gorm.DB, WXAppID string, WXAppSecret string) gin.HandlerFunc {
+func Login_wx(GlobalDatabase *gorm.DB, WXClient WXClient) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var request struct {
 			JsCode string `json:"code"`
+			Device string `json:"Device"`
 		}
-		if err := c.ShouldBindJSON(&request); err != nil {
+		if err := c.ShouldBindJSON(&request); err != nil || request.JsCode == "" {
 			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
 			return
 		}
-		wxLoginResp, err := _WXLogin(request.JsCode, WXAppID, WXAppSecret)
+		wxLoginResp, err := WXClient.Code2Session(request.JsCode)
 		if err != nil {
-			c.JSON(400, gin.H{"code": 2, "message": "内部错误"})
+			log.Printf("Failed to call jscode2session: %v\n", err)
+			c.JSON(400, gin.H{"code": 3, "message": "微信登陆失败"})
 			return
 		}
 
 		var login Login
-		if err := GlobalDatabase.Where("openid = ?", wxLoginResp.OpenId).First(&login).Error; err != nil {
-			//未注册
-			var user UserInfo
-			if err := GlobalDatabase.Create(&user).Error; err != nil {
-				c.JSON(400, gin.H{"code": 2, "message": "内部错误"})
-				return
+		var token *Token
+		var refreshToken string
+		newUser := false
+		err = GlobalDatabase.Transaction(func(tx *gorm.DB) error {
+			err := tx.Where("open_id = ?", wxLoginResp.OpenId).First(&login).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				//未注册 用户名密码可以之后通过/change_password设置
+				var user UserInfo
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				login = Login{
+					UserID:     user.ID,
+					OpenID:     wxLoginResp.OpenId,
+					SessionKey: wxLoginResp.SessionKey,
+					UnionID:    wxLoginResp.UnionId,
+				}
+				if err := tx.Create(&login).Error; err != nil {
+					return err
+				}
+				newUser = true
+			} else if err != nil {
+				return err
+			} else {
+				updateData := map[string]interface{}{"SessionKey": wxLoginResp.SessionKey}
+				if wxLoginResp.UnionId != "" {
+					updateData["UnionID"] = wxLoginResp.UnionId
+				}
+				if err := tx.Model(&Login{}).Where("user_id = ?", login.UserID).Updates(updateData).Error; err != nil {
+					return err
+				}
 			}
-			// username password unfinished
-			login = Login{OpenID: wxLoginResp.OpenId, UserID: user.ID}
-		}
-		token := Token{
-			UserID: login.UserID,
-			Token:  generateToken(login.UserID, JWTSecretKey),
-		}
-		c.JSON(200, gin.H{"code": 0, "message": "登录成功", "Token": token.Token, "UserID": token.UserID})
-	}
-}
-
-// @title         _WXLogin
-// @description   处理微信登陆的函数
-// @auth          DataEraserC               (2024/2/17   21:54)
-// @param         code              string              "微信小程序前端获得的jscode"
-// @param         AppID             string              "微信小程序AppID"
-// @param         AppSecret         string              "微信小程序AppSecret"
-// @return        wxResp            *WXLoginResp        "微信登陆返回值json对象"
-// @return        err               error               "可能存在的错误"
-func _WXLogin(code string, AppID string, AppSecret string) (*WXLoginResp, error) {
-
-	url := "https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code"
 
-	// 合成url, 这里的appId和secret是在微信公众平台上获取的
-	url = fmt.Sprintf(url, AppID, AppSecret, code)
-
-	// 创建http get请求
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// 解析http请求中body 数据到我们定义的结构体中
-	wxResp := WXLoginResp{}
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(&wxResp); err != nil {
-		return nil, err
-	}
+			token, refreshToken, err = _IssueSession(tx, login.UserID, _GetDevice(c, request.Device))
+			return err
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "内部错误"})
+			return
+		}
 
-	// 判断微信接口返回的是否是一个异常情况
-	if wxResp.ErrCode != 0 {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d  ErrMsg:%s", wxResp.ErrCode, wxResp.ErrMsg))
+		response := _SessionResponse("登录成功", token, refreshToken)
+		response["NewUser"] = newUser
+		c.JSON(200, response)
 	}
-
-	return &wxResp, nil
 }
 
+// Userinfo 获取当前登陆用户的信息,需要先经过AuthMiddleware
 func Userinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var request struct {
-			Token  string `json:"Token"`
-			UserID string `json:"UserID"`
-		}
-		if err := c.ShouldBindJSON(&request); err != nil {
-			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
-			return
-		}
-
-		var tokenRecord Token
-		if err := GlobalDatabase.Where("token = ?", request.Token).First(&tokenRecord).Error; err != nil {
-			c.JSON(400, gin.H{"code": 1, "message": "身份验证失败"})
-			return
-		}
-
 		var user UserInfo
-		if err := GlobalDatabase.First(&user, tokenRecord.UserID).Error; err != nil {
+		if err := GlobalDatabase.First(&user, _GetContextUserID(c)).Error; err != nil {
 			c.JSON(400, gin.H{"code": 1, "message": "获取用户信息失败"})
 			return
 		}
@@ -327,12 +413,12 @@ func Userinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// Updateuserinfo 更新用户信息，仅更新请求中包含的数据，不更新为空的字段
+// Updateuserinfo 更新用户信息，仅更新请求中包含的数据，不更新为空的字段,需要先经过AuthMiddleware
 func Updateuserinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
-			Token              string
-			UserID             uint
+			// 不给出UserID默认修改自己
+			UserID             *uint
 			Avatar             *string
 			Name               *string
 			NickName           *string
@@ -348,13 +434,8 @@ func Updateuserinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var tokenData Token
-		if err := GlobalDatabase.Model(&tokenData).Where("token = ?", request.Token).First(&tokenData).Error; err != nil {
-			c.JSON(400, gin.H{"code": 1, "message": "身份验证失败"})
-			return
-		}
-
-		if tokenData.UserID != request.UserID {
+		userID := _GetContextUserID(c)
+		if request.UserID != nil && *request.UserID != userID {
 			c.JSON(400, gin.H{"code": 3, "message": "无权限修改别人的信息"})
 			return
 		}
@@ -389,7 +470,18 @@ func Updateuserinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 		}
 
 		var user UserInfo
-		if err := GlobalDatabase.Model(&user).Where("ID = ?", tokenData.UserID).Updates(updateData).Error; err != nil {
+		if request.RegistrationNumber != nil {
+			// 导入名单时会按验证过的学号匹配用户,不能再改成别人的学号
+			if err := GlobalDatabase.First(&user, userID).Error; err != nil {
+				c.JSON(500, gin.H{"code": 2, "message": "修改个人信息失败"})
+				return
+			}
+			if user.RegistrationVerified && user.RegistrationNumber != *request.RegistrationNumber {
+				c.JSON(400, gin.H{"code": 37, "message": "学号已经验证,不能修改"})
+				return
+			}
+		}
+		if err := GlobalDatabase.Model(&user).Where("ID = ?", userID).Updates(updateData).Error; err != nil {
 			c.JSON(500, gin.H{"code": 2, "message": "修改个人信息失败"})
 			return
 		}
@@ -398,24 +490,254 @@ func Updateuserinfo(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// Logout 注销当前Token,需要先经过AuthMiddleware
 func Logout(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := _DeleteTokenByToken(GlobalDatabase, _GetContextToken(c)); err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "注销失败"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "注销成功"})
+	}
+}
+
+// @title         Register
+// @description   处理账号密码注册入口的函数,在同一事务中创建UserInfo及Login
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Register(GlobalDatabase *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var request struct {
-			Token string
+			Username string `json:"Username"`
+			Password string `json:"Password"`
+			NickName string `json:"NickName"`
 		}
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
 			return
 		}
 
-		var tokenData Token
-		if err := GlobalDatabase.Model(&tokenData).Where("token = ?", request.Token).First(&tokenData).Error; err != nil {
-			c.JSON(400, gin.H{"code": 1, "message": "身份验证失败"})
+		if err := validateUsername(request.Username); err != nil {
+			c.JSON(400, gin.H{"code": 2, "message": err.Error()})
+			return
+		}
+		if err := validatePassword(request.Password); err != nil {
+			c.JSON(400, gin.H{"code": 3, "message": err.Error()})
 			return
 		}
 
-		_DeleteTokenByToken(GlobalDatabase, tokenData.Token)
+		var count int64
+		if err := GlobalDatabase.Model(&Login{}).Where("username = ?", request.Username).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if count > 0 {
+			c.JSON(400, gin.H{"code": 4, "message": "用户名已存在"})
+			return
+		}
 
+		hashed, err := hashPassword(request.Password)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+
+		user := UserInfo{NickName: request.NickName}
+		err = GlobalDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			login := Login{UserID: user.ID, Username: request.Username, Password: hashed}
+			return tx.Create(&login).Error
+		})
+		if err != nil {
+			// 并发注册同名用户时由唯一约束兜底
+			c.JSON(400, gin.H{"code": 4, "message": "注册失败,用户名可能已存在"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "注册成功", "UserID": user.ID})
+	}
+}
+
+// @title         Change_password
+// @description   处理修改密码入口的函数,修改成功后撤销该用户的所有Token,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Change_password(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			OldPassword string
+			NewPassword string
+			// 仅微信登陆的账号第一次设置密码时需要同时设置用户名
+			Username string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		userID := _GetContextUserID(c)
+
+		var login Login
+		if err := GlobalDatabase.Where("user_id = ?", userID).First(&login).Error; err != nil {
+			c.JSON(400, gin.H{"code": 8, "message": "账号不存在"})
+			return
+		}
+
+		updateData := make(map[string]interface{})
+		if login.Password == "" {
+			// 账号还没有密码,无需校验旧密码
+			if login.Username == "" {
+				if err := validateUsername(request.Username); err != nil {
+					c.JSON(400, gin.H{"code": 2, "message": err.Error()})
+					return
+				}
+				updateData["Username"] = request.Username
+			}
+		} else if match, _, err := verifyPassword(login.Password, request.OldPassword); err != nil || !match {
+			c.JSON(400, gin.H{"code": 6, "message": "原密码错误"})
+			return
+		}
+
+		if err := validatePassword(request.NewPassword); err != nil {
+			c.JSON(400, gin.H{"code": 3, "message": err.Error()})
+			return
+		}
+		hashed, err := hashPassword(request.NewPassword)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		updateData["Password"] = hashed
+
+		// 修改密码与撤销所有Token在同一个事务中,撤销失败时密码也不会被修改
+		revokeFailed := false
+		err = GlobalDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&Login{}).Where("user_id = ?", userID).Updates(updateData).Error; err != nil {
+				return err
+			}
+			// 修改密码后所有已登陆的设备都需要重新登陆
+			if err := _DeleteTokensByUserID(tx, userID); err != nil {
+				revokeFailed = true
+				return err
+			}
+			return nil
+		})
+		if revokeFailed {
+			c.JSON(500, gin.H{"code": 7, "message": "撤销登陆状态失败,密码未修改"})
+			return
+		} else if err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "修改密码失败,用户名可能已存在"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "修改密码成功,请重新登陆"})
+	}
+}
+
+// @title         Bind_wx
+// @description   把微信OpenID绑定到当前登陆的账号,需要先经过AuthMiddleware
+// @auth          DataEraserC                    (2026/10/17   15:00)
+// @param         GlobalDatabase         *gorm.DB            "全局数据库"
+// @param         WXClient               WXClient            "微信接口客户端"
+// @return        匿名函数               gin.HandlerFunc     "gin消息中间件"
+func Bind_wx(GlobalDatabase *gorm.DB, WXClient WXClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			JsCode string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.JsCode == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		userID := _GetContextUserID(c)
+
+		var login Login
+		if err := GlobalDatabase.Where("user_id = ?", userID).First(&login).Error; err != nil {
+			c.JSON(400, gin.H{"code": 2, "message": "账号不存在"})
+			return
+		}
+
+		wxLoginResp, err := WXClient.Code2Session(request.JsCode)
+		if err != nil {
+			log.Printf("Failed to call jscode2session: %v\n", err)
+			c.JSON(400, gin.H{"code": 3, "message": "微信登陆失败"})
+			return
+		}
+
+		if login.OpenID == wxLoginResp.OpenId {
+			c.JSON(200, gin.H{"code": 0, "message": "已绑定该微信"})
+			return
+		}
+		if login.OpenID != "" {
+			c.JSON(400, gin.H{"code": 4, "message": "账号已绑定其他微信,请先解绑"})
+			return
+		}
+
+		var count int64
+		if err := GlobalDatabase.Model(&Login{}).Where("open_id = ?", wxLoginResp.OpenId).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"code": 6, "message": "内部错误"})
+			return
+		}
+		if count > 0 {
+			// 多半是之前直接用微信登陆产生了重复账号,需要管理员使用merge-user合并
+			c.JSON(400, gin.H{"code": 5, "message": "该微信已绑定其他账号,请联系管理员合并账号"})
+			return
+		}
+
+		updateData := map[string]interface{}{
+			"OpenID":     wxLoginResp.OpenId,
+			"SessionKey": wxLoginResp.SessionKey,
+			"UnionID":    wxLoginResp.UnionId,
+		}
+		if err := GlobalDatabase.Model(&Login{}).Where("user_id = ?", userID).Updates(updateData).Error; err != nil {
+			c.JSON(400, gin.H{"code": 5, "message": "该微信已绑定其他账号,请联系管理员合并账号"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "绑定微信成功"})
+	}
+}
+
+// @title         Unbind_wx
+// @description   解除当前登陆账号的微信绑定,账号必须已设置密码,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Unbind_wx(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := _GetContextUserID(c)
+
+		var login Login
+		if err := GlobalDatabase.Where("user_id = ?", userID).First(&login).Error; err != nil {
+			c.JSON(400, gin.H{"code": 2, "message": "账号不存在"})
+			return
+		}
+		if login.OpenID == "" {
+			c.JSON(400, gin.H{"code": 3, "message": "账号未绑定微信"})
+			return
+		}
+		// 没有用户名密码时解绑会导致账号再也无法登陆
+		if login.Username == "" || login.Password == "" {
+			c.JSON(400, gin.H{"code": 4, "message": "账号未设置用户名密码,无法解绑微信"})
+			return
+		}
+
+		updateData := map[string]interface{}{
+			"OpenID":     gorm.Expr("NULL"),
+			"SessionKey": "",
+			"UnionID":    "",
+		}
+		if err := GlobalDatabase.Model(&Login{}).Where("user_id = ?", userID).Updates(updateData).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "解绑微信成功"})
 	}
 }
 
@@ -446,3 +768,20 @@ func _GetUserIDByToken(TokenDatabase *gorm.DB, token string) (uint, error) {
 	}
 	return tokenData.UserID, nil
 }
+
+// @title         _CloseDatabase
+// @description   关闭动态加载的数据库(组织/会议/用户数据库用完后必须关闭)
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Database         *gorm.DB            "需要关闭的数据库"
+func _CloseDatabase(Database *gorm.DB) {
+	if Database == nil {
+		return
+	}
+	sqlDB, err := Database.DB()
+	if err != nil {
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("Failed to close database: %v\n", err)
+	}
+}
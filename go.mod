@@ -1,4 +1,4 @@
-module main
+module github.com/DataEraserC/RollCallApplet
 
 go 1.20
 
@@ -6,7 +6,7 @@ require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/glebarez/sqlite v1.10.0
 	github.com/golang-jwt/jwt v3.2.2+incompatible
-	go.starlark.net v0.0.0-20240123142251-f86470692795
+	golang.org/x/crypto v0.9.0
 	gorm.io/gorm v1.25.7
 )
 
@@ -35,7 +35,6 @@ require (
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	golang.org/x/arch v0.3.0 // indirect
-	golang.org/x/crypto v0.9.0 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
@@ -10,24 +10,81 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/glebarez/sqlite"
-	"go.starlark.net/lib/time"
 	"gorm.io/gorm"
 )
 
 // MemberInfo 成员信息gorm对象,记录了成员权限
 type MemberInfo struct {
-	UserID      uint
+	UserID      uint `gorm:"unique"`
 	Permissions string
+	// JoinedAt 加入组织的时间(unix时间戳),之前的会议不计入该成员的考勤,没有记录加入时间的成员为0
+	JoinedAt int64
+}
+
+// MemberHistory 已经退出或被移出组织的成员在组织中的一段时间,用于统计其在组织期间的考勤
+type MemberHistory struct {
+	ID          uint
+	UserID      uint `gorm:"index"`
+	Permissions string
+	JoinedAt    int64
+	LeftAt      int64
+}
+
+// 加入组织的方式
+const (
+	// JoinModeAuto 输入GroupCode后直接加入
+	JoinModeAuto = "auto"
+	// JoinModeApproval 输入GroupCode后提交加入申请,由组织管理员审核
+	JoinModeApproval = "approval"
+)
+
+// GroupSetting 组织设置gorm对象,每个组织数据库只有ID为1的一行
+type GroupSetting struct {
+	ID       uint
+	JoinMode string `gorm:"default:auto"`
+	// LateGraceMinutes 签到开始后多少分钟内签到不算迟到
+	LateGraceMinutes int `gorm:"default:5"`
+	// AttendanceRule 多次签到合并为会议考勤的规则 all/any
+	AttendanceRule string `gorm:"default:all"`
+}
+
+// JoinRequest 加入组织申请gorm对象,组织设置为需要审核时记录用户的加入申请
+type JoinRequest struct {
+	ID     uint
+	UserID uint `gorm:"index"`
+	Reason string
+	// Status 申请状态 pending/approved/rejected
+	Status       string `gorm:"index;default:pending"`
+	ReviewerID   uint
+	ReviewReason string
+	CreatedAt    int64
+	ReviewedAt   int64
 }
 
 // MeetingInfo 会议信息gorm对象,记录了对应ID的会议的会议描述及开始结束时间
 type MeetingInfo struct {
 	ID                 uint
-	BeginAt            time.Time
-	EndAt              time.Time
+	BeginAt            time.Time `gorm:"index"`
+	EndAt              time.Time `gorm:"index"`
 	MeetingDescription string
+	// Canceled 已取消的会议保留记录,但不能再修改或签到
+	Canceled bool `gorm:"index"`
+	// Geofence 会议的默认签到围栏,签到自己设置了围栏时以签到的为准
+	Geofence  Geofence `gorm:"embedded;embeddedPrefix:fence_"`
+	CreatedBy uint
+	CreatedAt int64
+	// SeriesID 所属的重复会议,单独安排的会议为0
+	SeriesID uint `gorm:"index"`
+	// OccurrenceDate 在重复会议中对应的日期(2006-01-02),单独修改时间后也不变
+	OccurrenceDate string
+	// Detached 单独修改或取消过,修改整个重复会议时不再跟随
+	Detached bool
 }
 
 // 每次要对组织数据库修改时必须先动态加载数据库
@@ -62,10 +119,854 @@ func InitGroup(GlobalPath string, GroupID uint, SafeMode bool) (*gorm.DB, error)
 		return nil, errors.New("failed to connect database")
 	}
 	if SafeMode {
-		err = GroupDatabase.AutoMigrate(&MemberInfo{}, &MeetingInfo{})
+		err = GroupDatabase.AutoMigrate(&MemberInfo{}, &MeetingInfo{}, &GroupSetting{}, &JoinRequest{}, &LeaveRequest{}, &AttendanceAppeal{}, &MeetingSeries{}, &MemberHistory{})
 		if err != nil {
 			return nil, errors.New("failed to AutoMigrate database")
 		}
 	}
 	return GroupDatabase, nil
 }
+
+// @title         _AddGroupMember
+// @description   把用户加入组织,同时写入组织数据库的MemberInfo和用户数据库的MemberOf,已是成员时不做修改
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupID                               uint                "组织ID"
+// @param         UserID                                uint                "用户ID"
+// @param         Permissions                           string              "用户在组织内的权限"
+// @return        err                                   error               "可能存在的错误"
+func _AddGroupMember(GlobalPath string, GroupID uint, UserID uint, Permissions string) error {
+	GroupDatabase, err := InitGroup(GlobalPath, GroupID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(GroupDatabase)
+	return _AddGroupMemberTo(GroupDatabase, GlobalPath, GroupID, UserID, Permissions)
+}
+
+// @title         _AddGroupMemberTo
+// @description   同_AddGroupMember,使用已经打开的组织数据库,用于批量加入成员
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupID                               uint                "组织ID"
+// @param         UserID                                uint                "用户ID"
+// @param         Permissions                           string              "用户在组织内的权限"
+// @return        err                                   error               "可能存在的错误"
+func _AddGroupMemberTo(GroupDatabase *gorm.DB, GlobalPath string, GroupID uint, UserID uint, Permissions string) error {
+	UserDatabase, err := InitUser(GlobalPath, UserID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(UserDatabase)
+
+	if err := GroupDatabase.Where(MemberInfo{UserID: UserID}).Attrs(MemberInfo{Permissions: Permissions, JoinedAt: time.Now().Unix()}).FirstOrCreate(&MemberInfo{}).Error; err != nil {
+		return err
+	}
+	return UserDatabase.Where(MemberOf{GroupID: GroupID}).Attrs(MemberOf{Permissions: Permissions}).FirstOrCreate(&MemberOf{}).Error
+}
+
+// @title         _SetMemberPermissions
+// @description   修改成员的权限,同时写入组织数据库的MemberInfo和用户数据库的MemberOf
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         UserID                                uint                "用户ID"
+// @param         Permissions                           string              "新的权限"
+// @return        err                                   error               "可能存在的错误"
+func _SetMemberPermissions(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, UserID uint, Permissions string) error {
+	result := GroupDatabase.Model(&MemberInfo{}).Where("user_id = ?", UserID).Update("permissions", Permissions)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	UserDatabase, err := InitUser(GlobalPath, UserID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(UserDatabase)
+	return UserDatabase.Where(MemberOf{GroupID: GroupID}).Assign(MemberOf{Permissions: Permissions}).FirstOrCreate(&MemberOf{}).Error
+}
+
+// @title         _RemoveGroupMember
+// @description   把成员移出组织,同时删除组织数据库的MemberInfo和用户数据库的MemberOf,在组织中的时间记录到MemberHistory
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         UserID                                uint                "用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _RemoveGroupMember(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, UserID uint) error {
+	err := GroupDatabase.Transaction(func(tx *gorm.DB) error {
+		var member MemberInfo
+		if err := tx.Where("user_id = ?", UserID).First(&member).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// 保留在组织中的时间,之前的会议仍然计入考勤统计
+		history := MemberHistory{UserID: UserID, Permissions: member.Permissions, JoinedAt: member.JoinedAt, LeftAt: time.Now().Unix()}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", UserID).Delete(&MemberInfo{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	UserDatabase, err := InitUser(GlobalPath, UserID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(UserDatabase)
+	return UserDatabase.Where("group_id = ?", GroupID).Delete(&MemberOf{}).Error
+}
+
+// @title         _SyncMemberPermissions
+// @description   以组织数据库的MemberInfo为准修复所有用户数据库中的MemberOf(补上缺少的、改正权限不一致的、删除多余的)
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @return        fixed                                 int                 "修复的MemberOf记录数"
+// @return        err                                   error               "可能存在的错误"
+func _SyncMemberPermissions(GlobalDatabase *gorm.DB, GlobalPath string) (int, error) {
+	var groups []GroupInfo
+	if err := GlobalDatabase.Find(&groups).Error; err != nil {
+		return 0, err
+	}
+	// expected[UserID][GroupID] = Permissions
+	expected := make(map[uint]map[uint]string)
+	for _, group := range groups {
+		GroupDatabase, err := InitGroup(GlobalPath, group.ID, true)
+		if err != nil {
+			return 0, err
+		}
+		var members []MemberInfo
+		err = GroupDatabase.Find(&members).Error
+		_CloseDatabase(GroupDatabase)
+		if err != nil {
+			return 0, err
+		}
+		for _, member := range members {
+			if expected[member.UserID] == nil {
+				expected[member.UserID] = make(map[uint]string)
+			}
+			expected[member.UserID][group.ID] = member.Permissions
+		}
+	}
+
+	var userIDs []uint
+	if err := GlobalDatabase.Model(&UserInfo{}).Pluck("id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+	fixed := 0
+	for _, userID := range userIDs {
+		_, statErr := os.Stat(fmt.Sprintf("%s/user/%d/database.db", GlobalPath, userID))
+		if os.IsNotExist(statErr) && len(expected[userID]) == 0 {
+			continue
+		}
+		UserDatabase, err := InitUser(GlobalPath, userID, true)
+		if err != nil {
+			return fixed, err
+		}
+		var memberOfs []MemberOf
+		if err := UserDatabase.Find(&memberOfs).Error; err != nil {
+			_CloseDatabase(UserDatabase)
+			return fixed, err
+		}
+		actual := make(map[uint]string)
+		for _, memberOf := range memberOfs {
+			actual[memberOf.GroupID] = memberOf.Permissions
+		}
+		for groupID, permissions := range expected[userID] {
+			if current, ok := actual[groupID]; ok && current == permissions {
+				continue
+			}
+			if err := UserDatabase.Where(MemberOf{GroupID: groupID}).Assign(MemberOf{Permissions: permissions}).FirstOrCreate(&MemberOf{}).Error; err != nil {
+				_CloseDatabase(UserDatabase)
+				return fixed, err
+			}
+			fixed++
+		}
+		for groupID := range actual {
+			if _, ok := expected[userID][groupID]; ok {
+				continue
+			}
+			if err := UserDatabase.Where("group_id = ?", groupID).Delete(&MemberOf{}).Error; err != nil {
+				_CloseDatabase(UserDatabase)
+				return fixed, err
+			}
+			fixed++
+		}
+		_CloseDatabase(UserDatabase)
+	}
+	return fixed, nil
+}
+
+// groupCodePattern 组织Code只允许字母数字及_-,用于手动加入组织
+var groupCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{4,32}$`)
+
+// @title         Create_group_request
+// @description   提交创建组织的申请,同一用户被拒绝的申请可以用相同的GroupCode重新提交,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Create_group_request(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Reason           string
+			GroupName        string
+			GroupCode        string
+			GroupDescription string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.GroupName == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if !groupCodePattern.MatchString(request.GroupCode) {
+			c.JSON(400, gin.H{"code": 2, "message": "组织Code只能包含字母、数字以及_-,长度4到32"})
+			return
+		}
+		userID := _GetContextUserID(c)
+
+		var count int64
+		if err := GlobalDatabase.Model(&GroupInfo{}).Where("group_code = ?", request.GroupCode).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+		if count > 0 {
+			c.JSON(400, gin.H{"code": 3, "message": "组织Code已被占用"})
+			return
+		}
+
+		var existing CreateGroupRequest
+		err := GlobalDatabase.Where("group_code = ?", request.GroupCode).First(&existing).Error
+		if err == nil {
+			if existing.UserID != userID || existing.Status != RequestStatusRejected {
+				c.JSON(400, gin.H{"code": 3, "message": "组织Code已被占用"})
+				return
+			}
+			// 重新提交自己被拒绝的申请
+			updateData := map[string]interface{}{
+				"Reason":           request.Reason,
+				"GroupName":        request.GroupName,
+				"GroupDescription": request.GroupDescription,
+				"Status":           RequestStatusPending,
+				"ReviewerID":       0,
+				"ReviewReason":     "",
+				"ReviewedAt":       0,
+			}
+			if err := GlobalDatabase.Model(&existing).Updates(updateData).Error; err != nil {
+				c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+				return
+			}
+			c.JSON(200, gin.H{"code": 0, "message": "已重新提交创建组织申请", "ID": existing.ID})
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+
+		createGroupRequest := CreateGroupRequest{
+			UserID:           userID,
+			Reason:           request.Reason,
+			GroupName:        request.GroupName,
+			GroupCode:        request.GroupCode,
+			GroupDescription: request.GroupDescription,
+			Status:           RequestStatusPending,
+		}
+		if err := GlobalDatabase.Create(&createGroupRequest).Error; err != nil {
+			c.JSON(400, gin.H{"code": 3, "message": "组织Code已被占用"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已提交创建组织申请", "ID": createGroupRequest.ID})
+	}
+}
+
+// @title         My_create_group_requests
+// @description   列出当前用户提交的创建组织申请及其状态,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_create_group_requests(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requests []CreateGroupRequest
+		if err := GlobalDatabase.Where("user_id = ?", _GetContextUserID(c)).Order("id DESC").Find(&requests).Error; err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "获取申请列表失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取申请列表成功", "data": requests})
+	}
+}
+
+// @title         Create_group_requests
+// @description   站点管理员列出创建组织的申请,可按状态筛选,需要先经过SiteAdminMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Create_group_requests(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// Status 为空时列出全部
+			Status string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		query := GlobalDatabase.Order("id DESC")
+		if request.Status != "" {
+			query = query.Where("status = ?", request.Status)
+		}
+		var requests []CreateGroupRequest
+		if err := query.Find(&requests).Error; err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "获取申请列表失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取申请列表成功", "data": requests})
+	}
+}
+
+// @title         Review_create_group_request
+// @description   站点管理员审核创建组织的申请,通过时创建GroupInfo及组织数据库并把申请人设为组织所有者,需要先经过SiteAdminMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Review_create_group_request(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID      uint
+			Approve bool
+			Reason  string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		reviewerID := _GetContextUserID(c)
+
+		var createGroupRequest CreateGroupRequest
+		if err := GlobalDatabase.First(&createGroupRequest, request.ID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 2, "message": "申请不存在"})
+			return
+		}
+		if createGroupRequest.Status != RequestStatusPending {
+			c.JSON(400, gin.H{"code": 3, "message": "申请已被审核"})
+			return
+		}
+
+		reviewData := map[string]interface{}{
+			"ReviewerID":   reviewerID,
+			"ReviewReason": request.Reason,
+			"ReviewedAt":   time.Now().Unix(),
+		}
+
+		if !request.Approve {
+			reviewData["Status"] = RequestStatusRejected
+			result := GlobalDatabase.Model(&CreateGroupRequest{}).
+				Where("id = ? AND status = ?", request.ID, RequestStatusPending).
+				Updates(reviewData)
+			if result.Error != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+			if result.RowsAffected == 0 {
+				c.JSON(400, gin.H{"code": 3, "message": "申请已被审核"})
+				return
+			}
+			c.JSON(200, gin.H{"code": 0, "message": "已拒绝创建组织申请"})
+			return
+		}
+
+		group := GroupInfo{
+			GroupCode:        createGroupRequest.GroupCode,
+			GroupName:        createGroupRequest.GroupName,
+			GroupDescription: createGroupRequest.GroupDescription,
+			OwnerID:          createGroupRequest.UserID,
+		}
+		err := GlobalDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&group).Error; err != nil {
+				return err
+			}
+			reviewData["Status"] = RequestStatusApproved
+			reviewData["GroupID"] = group.ID
+			result := tx.Model(&CreateGroupRequest{}).
+				Where("id = ? AND status = ?", request.ID, RequestStatusPending).
+				Updates(reviewData)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("申请已被审核")
+			}
+
+			// 组织及用户数据库写入失败时回滚,并删除可能已创建的组织文件夹,避免之后复用该ID时读到残留数据
+			if err := _AddGroupMember(GlobalPath, group.ID, createGroupRequest.UserID, GroupOwnerPermissions); err != nil {
+				if err := os.RemoveAll(fmt.Sprintf("%s/group/%d", GlobalPath, group.ID)); err != nil {
+					log.Printf("Failed to remove group directory of %d: %v\n", group.ID, err)
+				}
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to approve create group request %d: %v\n", request.ID, err)
+			c.JSON(500, gin.H{"code": 4, "message": "创建组织失败"})
+			return
+		}
+
+		c.JSON(200, gin.H{"code": 0, "message": "已通过创建组织申请", "GroupID": group.ID})
+	}
+}
+
+// @title         _GetGroupSetting
+// @description   读取组织设置,不存在时使用默认设置创建
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @return        setting                               GroupSetting        "组织设置"
+// @return        err                                   error               "可能存在的错误"
+func _GetGroupSetting(GroupDatabase *gorm.DB) (GroupSetting, error) {
+	setting := GroupSetting{ID: 1}
+	err := GroupDatabase.Where(GroupSetting{ID: 1}).Attrs(GroupSetting{JoinMode: JoinModeAuto, LateGraceMinutes: AttendanceDefaultLateGrace, AttendanceRule: AttendanceRuleAll}).FirstOrCreate(&setting).Error
+	return setting, err
+}
+
+// @title         _GetGroupInfo
+// @description   从全局数据库读取组织信息,用于在动态加载组织数据库之前确认组织存在
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GroupID                               uint                "组织ID"
+// @return        group                                 GroupInfo           "组织信息"
+// @return        err                                   error               "可能存在的错误"
+func _GetGroupInfo(GlobalDatabase *gorm.DB, GroupID uint) (GroupInfo, error) {
+	var group GroupInfo
+	err := GlobalDatabase.First(&group, GroupID).Error
+	return group, err
+}
+
+// @title         Join_group
+// @description   通过GroupCode加入组织,组织设置为需要审核时提交加入申请,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Join_group(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			GroupCode string
+			Reason    string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.GroupCode == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		userID := _GetContextUserID(c)
+
+		var group GroupInfo
+		if err := GlobalDatabase.Where("group_code = ?", request.GroupCode).First(&group).Error; err != nil {
+			c.JSON(400, gin.H{"code": 2, "message": "组织不存在"})
+			return
+		}
+
+		GroupDatabase, err := InitGroup(GlobalPath, group.ID, true)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		defer _CloseDatabase(GroupDatabase)
+
+		var count int64
+		if err := GroupDatabase.Model(&MemberInfo{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if count > 0 {
+			c.JSON(400, gin.H{"code": 3, "message": "已经是组织成员"})
+			return
+		}
+
+		setting, err := _GetGroupSetting(GroupDatabase)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+
+		if setting.JoinMode != JoinModeApproval {
+			if err := _AddGroupMember(GlobalPath, group.ID, userID, GroupMemberPermissions); err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "加入组织失败"})
+				return
+			}
+			c.JSON(200, gin.H{"code": 0, "message": "加入组织成功", "GroupID": group.ID, "Pending": false})
+			return
+		}
+
+		if err := GroupDatabase.Where("user_id = ? AND status = ?", userID, RequestStatusPending).First(&JoinRequest{}).Error; err == nil {
+			c.JSON(400, gin.H{"code": 4, "message": "已提交加入申请,请等待审核"})
+			return
+		}
+		joinRequest := JoinRequest{UserID: userID, Reason: request.Reason, Status: RequestStatusPending}
+		if err := GroupDatabase.Create(&joinRequest).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已提交加入申请,请等待审核", "GroupID": group.ID, "Pending": true, "ID": joinRequest.ID})
+	}
+}
+
+// @title         Join_requests
+// @description   组织管理员列出加入申请,可按状态筛选,需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Join_requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// Status 为空时列出全部
+			Status string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		query := _GetContextGroupDatabase(c).Order("id DESC")
+		if request.Status != "" {
+			query = query.Where("status = ?", request.Status)
+		}
+		var joinRequests []JoinRequest
+		if err := query.Find(&joinRequests).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取加入申请成功", "data": joinRequests})
+	}
+}
+
+// @title         Review_join_request
+// @description   组织管理员审核加入申请,通过时把申请人加入组织,需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Review_join_request(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID      uint
+			Approve bool
+			Reason  string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+
+		var joinRequest JoinRequest
+		if err := GroupDatabase.First(&joinRequest, request.ID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "申请不存在"})
+			return
+		}
+
+		status := RequestStatusRejected
+		if request.Approve {
+			status = RequestStatusApproved
+		}
+		result := GroupDatabase.Model(&JoinRequest{}).
+			Where("id = ? AND status = ?", request.ID, RequestStatusPending).
+			Updates(map[string]interface{}{
+				"Status":       status,
+				"ReviewerID":   _GetContextUserID(c),
+				"ReviewReason": request.Reason,
+				"ReviewedAt":   time.Now().Unix(),
+			})
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 6, "message": "申请已被审核"})
+			return
+		}
+
+		if !request.Approve {
+			c.JSON(200, gin.H{"code": 0, "message": "已拒绝加入申请"})
+			return
+		}
+		if err := _AddGroupMember(GlobalPath, _GetContextGroupID(c), joinRequest.UserID, GroupMemberPermissions); err != nil {
+			// 把申请恢复为待审核,管理员可以重试
+			GroupDatabase.Model(&JoinRequest{}).Where("id = ?", request.ID).Update("status", RequestStatusPending)
+			c.JSON(500, gin.H{"code": 5, "message": "加入组织失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已通过加入申请"})
+	}
+}
+
+// @title         Update_group_setting
+// @description   组织管理员修改组织设置,需要先经过GroupMiddleware(CapManageGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Update_group_setting() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			JoinMode         *string
+			LateGraceMinutes *int
+			AttendanceRule   *string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if request.JoinMode != nil && *request.JoinMode != JoinModeAuto && *request.JoinMode != JoinModeApproval {
+			c.JSON(400, gin.H{"code": 1, "message": "JoinMode只能是auto或approval"})
+			return
+		}
+		if request.LateGraceMinutes != nil && (*request.LateGraceMinutes < 0 || *request.LateGraceMinutes > AttendanceMaxLateGrace) {
+			c.JSON(400, gin.H{"code": 1, "message": fmt.Sprintf("LateGraceMinutes必须在0到%d之间", AttendanceMaxLateGrace)})
+			return
+		}
+		if request.AttendanceRule != nil && *request.AttendanceRule != AttendanceRuleAll && *request.AttendanceRule != AttendanceRuleAny {
+			c.JSON(400, gin.H{"code": 1, "message": "AttendanceRule只能是all或any"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		setting, err := _GetGroupSetting(GroupDatabase)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		updateData := make(map[string]interface{})
+		if request.JoinMode != nil {
+			setting.JoinMode = *request.JoinMode
+			updateData["JoinMode"] = setting.JoinMode
+		}
+		if request.LateGraceMinutes != nil {
+			setting.LateGraceMinutes = *request.LateGraceMinutes
+			updateData["LateGraceMinutes"] = setting.LateGraceMinutes
+		}
+		if request.AttendanceRule != nil {
+			setting.AttendanceRule = *request.AttendanceRule
+			updateData["AttendanceRule"] = setting.AttendanceRule
+		}
+		if len(updateData) > 0 {
+			if err := GroupDatabase.Model(&setting).Updates(updateData).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "修改组织设置成功", "data": setting})
+	}
+}
+
+// @title         My_groups
+// @description   列出当前用户加入的组织及在各组织内的权限,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_groups(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		UserDatabase, err := InitUser(GlobalPath, _GetContextUserID(c), true)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		defer _CloseDatabase(UserDatabase)
+
+		var memberOfs []MemberOf
+		if err := UserDatabase.Find(&memberOfs).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		data := []gin.H{}
+		for _, memberOf := range memberOfs {
+			group, err := _GetGroupInfo(GlobalDatabase, memberOf.GroupID)
+			if err != nil {
+				// 组织已被删除,等待sync-permissions清理
+				continue
+			}
+			permissions, _ := ParseGroupPermissions(memberOf.Permissions)
+			data = append(data, gin.H{
+				"GroupID":          group.ID,
+				"GroupCode":        group.GroupCode,
+				"GroupName":        group.GroupName,
+				"GroupDescription": group.GroupDescription,
+				"Permissions":      memberOf.Permissions,
+				"Role":             permissions.Role,
+				"Capabilities":     permissions.Capabilities(),
+			})
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取组织列表成功", "data": data})
+	}
+}
+
+// @title         Group_members
+// @description   列出组织成员及其角色,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Group_members(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var members []MemberInfo
+		if err := _GetContextGroupDatabase(c).Find(&members).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		userIDs := make([]uint, 0, len(members))
+		for _, member := range members {
+			userIDs = append(userIDs, member.UserID)
+		}
+		var users []UserInfo
+		if err := GlobalDatabase.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		userMap := make(map[uint]UserInfo)
+		for _, user := range users {
+			userMap[user.ID] = user
+		}
+
+		data := make([]gin.H, 0, len(members))
+		for _, member := range members {
+			permissions, _ := ParseGroupPermissions(member.Permissions)
+			user := userMap[member.UserID]
+			data = append(data, gin.H{
+				"UserID":       member.UserID,
+				"Name":         user.Name,
+				"NickName":     user.NickName,
+				"Avatar":       user.Avatar,
+				"Placeholder":  user.Placeholder,
+				"Permissions":  member.Permissions,
+				"Role":         permissions.Role,
+				"Capabilities": permissions.Capabilities(),
+			})
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取组织成员成功", "data": data})
+	}
+}
+
+// @title         Set_member_permissions
+// @description   修改成员的角色及细分权限,只能管理角色比自己低的成员且只能授予自己拥有的权限(组织创建者不受限制),需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Set_member_permissions(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			UserID      uint
+			Permissions string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.UserID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		newPermissions, err := ParseGroupPermissions(request.Permissions)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "权限格式错误: " + err.Error()})
+			return
+		}
+		if newPermissions.Role == GroupOwnerPermissions {
+			c.JSON(400, gin.H{"code": 1, "message": "不能把成员设为组织创建者"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		operator := _GetContextGroupPermissions(c)
+		current, err := _GetMemberPermissions(GroupDatabase, request.UserID)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "该用户不是组织成员"})
+			return
+		}
+		if current.Role == GroupOwnerPermissions {
+			c.JSON(403, gin.H{"code": 3, "message": "不能修改组织创建者的权限"})
+			return
+		}
+		if operator.Role != GroupOwnerPermissions {
+			if current.Rank() >= operator.Rank() || newPermissions.Rank() >= operator.Rank() {
+				c.JSON(403, gin.H{"code": 3, "message": "只能管理角色比自己低的成员"})
+				return
+			}
+			for _, capability := range newPermissions.Capabilities() {
+				if !operator.Has(capability) {
+					c.JSON(403, gin.H{"code": 3, "message": "不能授予自己没有的权限" + capability})
+					return
+				}
+			}
+		}
+
+		permissions := newPermissions.String()
+		if err := _SetMemberPermissions(GlobalPath, GroupDatabase, _GetContextGroupID(c), request.UserID, permissions); err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "修改成员权限成功", "data": gin.H{
+			"UserID":       request.UserID,
+			"Permissions":  permissions,
+			"Role":         newPermissions.Role,
+			"Capabilities": newPermissions.Capabilities(),
+		}})
+	}
+}
+
+// @title         Remove_member
+// @description   把角色比自己低的成员移出组织,需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Remove_member(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			UserID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.UserID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		operator := _GetContextGroupPermissions(c)
+		target, err := _GetMemberPermissions(GroupDatabase, request.UserID)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "该用户不是组织成员"})
+			return
+		}
+		if target.Role == GroupOwnerPermissions {
+			c.JSON(403, gin.H{"code": 3, "message": "不能移除组织创建者"})
+			return
+		}
+		if operator.Role != GroupOwnerPermissions && target.Rank() >= operator.Rank() {
+			c.JSON(403, gin.H{"code": 3, "message": "只能管理角色比自己低的成员"})
+			return
+		}
+
+		if err := _RemoveGroupMember(GlobalPath, GroupDatabase, _GetContextGroupID(c), request.UserID); err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已移除成员"})
+	}
+}
+
+// @title         Leave_group
+// @description   退出组织,组织创建者不能退出,需要先经过GroupMiddleware(只要求是成员)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Leave_group(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _GetContextGroupPermissions(c).Role == GroupOwnerPermissions {
+			c.JSON(400, gin.H{"code": 4, "message": "组织创建者不能退出组织"})
+			return
+		}
+		if err := _RemoveGroupMember(GlobalPath, _GetContextGroupDatabase(c), _GetContextGroupID(c), _GetContextUserID(c)); err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已退出组织"})
+	}
+}
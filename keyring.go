@@ -0,0 +1,263 @@
+// @Title       keyring.go
+// @Description 放置jwt签名密钥环(多个带kid的密钥,一个用于签名,其余仅用于校验直到退役)及其加载、轮换的工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LegacyJWTKeyID 由环境变量JWTSecretKey导入的密钥的kid,没有kid头的旧Token也用它校验
+const LegacyJWTKeyID = "default"
+
+// JWTKey 单个jwt签名密钥
+type JWTKey struct {
+	ID        string `json:"kid"`
+	Secret    string `json:"secret"`
+	CreatedAt int64  `json:"created_at"`
+	// Retired 退役的密钥不再用于校验,用它签名的Token全部失效
+	Retired bool `json:"retired"`
+}
+
+// JWTKeyring jwt签名密钥环,ActiveKeyID指向的密钥用于签名,其余未退役的密钥仍用于校验
+type JWTKeyring struct {
+	ActiveKeyID string   `json:"active"`
+	Keys        []JWTKey `json:"keys"`
+
+	mutex sync.RWMutex
+	// path 密钥环文件路径,为空表示只存在于内存中(仅由环境变量导入)
+	path    string
+	modTime time.Time
+}
+
+// @title         LoadJWTKeyring
+// @description   加载密钥环:文件存在时读取文件,否则使用环境变量中的密钥,两者都没有时生成新的密钥环并保存
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         Path                          string              "密钥环文件路径"
+// @param         LegacySecret                  string              "环境变量JWTSecretKey中的密钥"
+// @return        keyring                       *JWTKeyring         "密钥环"
+// @return        err                           error               "可能存在的错误"
+func LoadJWTKeyring(Path string, LegacySecret string) (*JWTKeyring, error) {
+	keyring := &JWTKeyring{path: Path}
+	err := keyring.load()
+	if err == nil {
+		return keyring, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if LegacySecret != "" {
+		keyring.Keys = []JWTKey{{ID: LegacyJWTKeyID, Secret: LegacySecret, CreatedAt: time.Now().Unix()}}
+		keyring.ActiveKeyID = LegacyJWTKeyID
+		// 不写入文件,避免把环境变量中的密钥落盘;轮换时才会保存
+		return keyring, nil
+	}
+
+	log.Println("No jwt keyring found, generating a new one......")
+	if _, err := keyring.Rotate(); err != nil {
+		return nil, err
+	}
+	if err := keyring.Save(); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// load 从文件读取密钥环
+func (keyring *JWTKeyring) load() error {
+	info, err := os.Stat(keyring.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(keyring.path)
+	if err != nil {
+		return err
+	}
+
+	var loaded struct {
+		ActiveKeyID string   `json:"active"`
+		Keys        []JWTKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("密钥环文件格式错误: %w", err)
+	}
+	if _, err := findJWTKey(loaded.Keys, loaded.ActiveKeyID); err != nil {
+		return fmt.Errorf("密钥环中找不到用于签名的密钥%q", loaded.ActiveKeyID)
+	}
+
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	keyring.ActiveKeyID = loaded.ActiveKeyID
+	keyring.Keys = loaded.Keys
+	keyring.modTime = info.ModTime()
+	return nil
+}
+
+// @title         findJWTKey
+// @description   按kid查找未退役的密钥
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         keys             []JWTKey            "密钥列表"
+// @param         kid              string              "密钥ID"
+// @return        key              JWTKey              "找到的密钥"
+// @return        err              error               "找不到或已退役时的错误"
+func findJWTKey(keys []JWTKey, kid string) (JWTKey, error) {
+	for _, key := range keys {
+		if key.ID == kid {
+			if key.Retired {
+				return JWTKey{}, fmt.Errorf("密钥%q已退役", kid)
+			}
+			return key, nil
+		}
+	}
+	return JWTKey{}, fmt.Errorf("未知的密钥%q", kid)
+}
+
+// @title         ActiveKey
+// @description   获取用于签名的密钥
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        key              JWTKey              "用于签名的密钥"
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) ActiveKey() (JWTKey, error) {
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+	return findJWTKey(keyring.Keys, keyring.ActiveKeyID)
+}
+
+// @title         VerificationKey
+// @description   获取用于校验的密钥,kid为空时(旧Token)使用LegacyJWTKeyID
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         kid              string              "Token头中的kid"
+// @return        key              JWTKey              "用于校验的密钥"
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) VerificationKey(kid string) (JWTKey, error) {
+	if kid == "" {
+		kid = LegacyJWTKeyID
+	}
+	keyring.mutex.RLock()
+	defer keyring.mutex.RUnlock()
+	return findJWTKey(keyring.Keys, kid)
+}
+
+// @title         Rotate
+// @description   生成新的随机密钥并设为签名密钥,旧密钥仍可用于校验
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        key              JWTKey              "新的签名密钥"
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) Rotate() (JWTKey, error) {
+	now := time.Now()
+	key := JWTKey{
+		ID:        fmt.Sprintf("%s-%s", now.Format("20060102"), randomHex(4)),
+		Secret:    randomHex(32),
+		CreatedAt: now.Unix(),
+	}
+
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	keyring.Keys = append(keyring.Keys, key)
+	keyring.ActiveKeyID = key.ID
+	return key, nil
+}
+
+// @title         Retire
+// @description   退役某个密钥,用它签名的Token立即失效,不能退役当前的签名密钥
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         kid              string              "密钥ID"
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) Retire(kid string) error {
+	keyring.mutex.Lock()
+	defer keyring.mutex.Unlock()
+	if kid == keyring.ActiveKeyID {
+		return errors.New("不能退役当前用于签名的密钥,请先轮换")
+	}
+	for i := range keyring.Keys {
+		if keyring.Keys[i].ID == kid {
+			keyring.Keys[i].Retired = true
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的密钥%q", kid)
+}
+
+// @title         Save
+// @description   把密钥环写入文件(先写临时文件再重命名,避免运行中的服务读到写了一半的文件)
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) Save() error {
+	if keyring.path == "" {
+		return errors.New("密钥环没有指定文件路径")
+	}
+	keyring.mutex.RLock()
+	data, err := json.MarshalIndent(keyring, "", "  ")
+	keyring.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyring.path), 0755); err != nil {
+		return err
+	}
+	tmpPath := keyring.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, keyring.path)
+}
+
+// @title         ReloadIfChanged
+// @description   密钥环文件被修改(比如执行了rotate-jwt-key)时重新加载
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        reloaded         bool                "是否重新加载了"
+// @return        err              error               "可能存在的错误"
+func (keyring *JWTKeyring) ReloadIfChanged() (bool, error) {
+	if keyring.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(keyring.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	keyring.mutex.RLock()
+	changed := !info.ModTime().Equal(keyring.modTime)
+	keyring.mutex.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	if err := keyring.load(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// @title         StartJWTKeyringWatcher
+// @description   在后台定期检查密钥环文件,被修改时重新加载
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         keyring                       *JWTKeyring         "密钥环"
+// @param         Interval                      time.Duration       "检查间隔"
+func StartJWTKeyringWatcher(keyring *JWTKeyring, Interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reloaded, err := keyring.ReloadIfChanged()
+			if err != nil {
+				// 保留已加载的密钥环继续运行
+				log.Printf("Failed to reload jwt keyring: %v\n", err)
+			} else if reloaded {
+				log.Println("Reloaded jwt keyring")
+			}
+		}
+	}()
+}
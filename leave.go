@@ -0,0 +1,378 @@
+// @Title       leave.go
+// @Description 放置请假(成员提交请假申请,组织者审核,通过后考勤记为请假)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 请假附件的参数
+var (
+	// LeaveAttachmentMaxSize 附件最大字节数
+	LeaveAttachmentMaxSize = 2 << 20
+	// LeaveAttachmentTypes 允许上传的附件类型(病假条照片或扫描件)
+	LeaveAttachmentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "application/pdf": true}
+)
+
+// LeaveRequest 请假申请gorm对象,可以针对某次会议或者一段时间请假
+type LeaveRequest struct {
+	ID     uint
+	UserID uint `gorm:"index"`
+	// MeetingID 请假的会议,按时间段请假时为0
+	MeetingID uint `gorm:"index"`
+	// BeginAt EndAt 请假的时间段,按会议请假时为提交时会议的时间
+	BeginAt time.Time
+	EndAt   time.Time
+	Reason  string
+	// AttachmentName AttachmentType 附件的文件名及类型,没有附件时为空
+	AttachmentName string
+	AttachmentType string
+	// Status 申请状态 pending/approved/rejected/canceled
+	Status       string `gorm:"index;default:pending"`
+	ReviewerID   uint
+	ReviewReason string
+	CreatedAt    int64
+	ReviewedAt   int64
+}
+
+// @title         _LeaveAttachmentPath
+// @description   请假附件的保存路径
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupID                               uint                "组织ID"
+// @param         LeaveID                               uint                "请假申请ID"
+// @return        path                                  string              "附件路径"
+func _LeaveAttachmentPath(GlobalPath string, GroupID uint, LeaveID uint) string {
+	return fmt.Sprintf("%s/group/%d/leave/%d", GlobalPath, GroupID, LeaveID)
+}
+
+// @title         _ExcusedUserIDs
+// @description   列出请假已通过的成员:针对这次会议请假,或者请假时间段与会议时间有重叠
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         meeting                               MeetingInfo         "会议"
+// @return        excused                               map[uint]bool       "请假的成员"
+// @return        err                                   error               "可能存在的错误"
+func _ExcusedUserIDs(GroupDatabase *gorm.DB, meeting MeetingInfo) (map[uint]bool, error) {
+	var userIDs []uint
+	err := GroupDatabase.Model(&LeaveRequest{}).
+		Where("status = ?", RequestStatusApproved).
+		Where("meeting_id = ? OR (meeting_id = 0 AND begin_at < ? AND end_at > ?)", meeting.ID, meeting.EndAt, meeting.BeginAt).
+		Pluck("user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	excused := make(map[uint]bool)
+	for _, userID := range userIDs {
+		excused[userID] = true
+	}
+	return excused, nil
+}
+
+// @title         Request_leave
+// @description   成员针对某次会议或者一段时间提交请假申请,可以附带不超过2MB的图片或PDF,需要先经过GroupMiddleware(CapSignIn)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Request_leave(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// MeetingID 和BeginAt、EndAt二选一,MeetingID优先
+			MeetingID uint
+			BeginAt   *time.Time
+			EndAt     *time.Time
+			Reason    string
+			// AttachmentName 附件的文件名
+			AttachmentName string
+			// Attachment base64编码的附件内容
+			Attachment string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+		if request.Reason == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "请填写请假原因"})
+			return
+		}
+
+		var attachment []byte
+		leave := LeaveRequest{
+			UserID:    _GetContextUserID(c),
+			MeetingID: request.MeetingID,
+			Reason:    request.Reason,
+			Status:    RequestStatusPending,
+			CreatedAt: time.Now().Unix(),
+		}
+		if request.Attachment != "" {
+			var err error
+			attachment, err = base64.StdEncoding.DecodeString(request.Attachment)
+			if err != nil {
+				c.JSON(400, gin.H{"code": 1, "message": "附件不是合法的base64"})
+				return
+			}
+			if len(attachment) > LeaveAttachmentMaxSize {
+				c.JSON(400, gin.H{"code": 1, "message": fmt.Sprintf("附件不能超过%dKB", LeaveAttachmentMaxSize>>10)})
+				return
+			}
+			leave.AttachmentType = http.DetectContentType(attachment)
+			if !LeaveAttachmentTypes[leave.AttachmentType] {
+				c.JSON(400, gin.H{"code": 1, "message": "附件只能是jpeg、png图片或pdf"})
+				return
+			}
+			leave.AttachmentName = filepath.Base(request.AttachmentName)
+			if leave.AttachmentName == "." || leave.AttachmentName == "/" {
+				leave.AttachmentName = "attachment"
+			}
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		if request.MeetingID != 0 {
+			meeting, err := _GetMeeting(GroupDatabase, request.MeetingID)
+			if err != nil {
+				c.JSON(400, gin.H{"code": 4, "message": "会议不存在"})
+				return
+			}
+			if meeting.Canceled {
+				c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+				return
+			}
+			var count int64
+			if err := GroupDatabase.Model(&LeaveRequest{}).
+				Where("user_id = ? AND meeting_id = ? AND status IN ?", leave.UserID, meeting.ID, []string{RequestStatusPending, RequestStatusApproved}).
+				Count(&count).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+			if count > 0 {
+				c.JSON(400, gin.H{"code": 22, "message": "已经提交过这次会议的请假申请"})
+				return
+			}
+			leave.BeginAt = meeting.BeginAt
+			leave.EndAt = meeting.EndAt
+		} else {
+			if request.BeginAt == nil || request.EndAt == nil {
+				c.JSON(400, gin.H{"code": 1, "message": "必须指定MeetingID或BeginAt、EndAt"})
+				return
+			}
+			leave.BeginAt = request.BeginAt.Local()
+			leave.EndAt = request.EndAt.Local()
+			if !leave.EndAt.After(leave.BeginAt) {
+				c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+				return
+			}
+		}
+
+		if err := GroupDatabase.Create(&leave).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if attachment != nil {
+			path := _LeaveAttachmentPath(GlobalPath, _GetContextGroupID(c), leave.ID)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			if err == nil {
+				err = os.WriteFile(path, attachment, 0644)
+			}
+			if err != nil {
+				GroupDatabase.Delete(&leave)
+				c.JSON(500, gin.H{"code": 5, "message": "保存附件失败"})
+				return
+			}
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已提交请假申请", "data": leave})
+	}
+}
+
+// @title         My_leave_requests
+// @description   列出自己在组织内的请假申请,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_leave_requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var leaves []LeaveRequest
+		if err := _GetContextGroupDatabase(c).Where("user_id = ?", _GetContextUserID(c)).Order("id DESC").Find(&leaves).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取请假申请成功", "data": leaves})
+	}
+}
+
+// @title         Cancel_leave_request
+// @description   撤回自己尚未审核的请假申请,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Cancel_leave_request() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+
+		var leave LeaveRequest
+		if err := GroupDatabase.Where("id = ? AND user_id = ?", request.ID, _GetContextUserID(c)).First(&leave).Error; err != nil {
+			c.JSON(400, gin.H{"code": 20, "message": "请假申请不存在"})
+			return
+		}
+		result := GroupDatabase.Model(&LeaveRequest{}).
+			Where("id = ? AND status = ?", leave.ID, RequestStatusPending).
+			Update("status", RequestStatusCanceled)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 21, "message": "请假申请已被审核或撤回"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已撤回请假申请"})
+	}
+}
+
+// @title         Leave_requests
+// @description   组织者列出组织内的请假申请,需要先经过GroupMiddleware(CapReviewLeave)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Leave_requests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// Status 为空时列出全部
+			Status string
+			// UserID MeetingID 为0时不筛选
+			UserID    uint
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		query := _GetContextGroupDatabase(c).Order("id DESC")
+		if request.Status != "" {
+			query = query.Where("status = ?", request.Status)
+		}
+		if request.UserID != 0 {
+			query = query.Where("user_id = ?", request.UserID)
+		}
+		if request.MeetingID != 0 {
+			query = query.Where("meeting_id = ?", request.MeetingID)
+		}
+		var leaves []LeaveRequest
+		if err := query.Find(&leaves).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取请假申请成功", "data": leaves})
+	}
+}
+
+// @title         Review_leave_request
+// @description   组织者审核请假申请,不能审核自己的申请,需要先经过GroupMiddleware(CapReviewLeave)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Review_leave_request() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID      uint
+			Approve bool
+			Reason  string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+
+		var leave LeaveRequest
+		if err := GroupDatabase.First(&leave, request.ID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 20, "message": "请假申请不存在"})
+			return
+		}
+		if leave.UserID == _GetContextUserID(c) {
+			c.JSON(403, gin.H{"code": 3, "message": "不能审核自己的请假申请"})
+			return
+		}
+
+		status := RequestStatusRejected
+		if request.Approve {
+			status = RequestStatusApproved
+		}
+		result := GroupDatabase.Model(&LeaveRequest{}).
+			Where("id = ? AND status = ?", request.ID, RequestStatusPending).
+			Updates(map[string]interface{}{
+				"Status":       status,
+				"ReviewerID":   _GetContextUserID(c),
+				"ReviewReason": request.Reason,
+				"ReviewedAt":   time.Now().Unix(),
+			})
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 21, "message": "请假申请已被审核或撤回"})
+			return
+		}
+		if request.Approve {
+			c.JSON(200, gin.H{"code": 0, "message": "已批准请假"})
+		} else {
+			c.JSON(200, gin.H{"code": 0, "message": "已拒绝请假"})
+		}
+	}
+}
+
+// @title         Leave_attachment
+// @description   下载请假申请的附件,只有申请人及拥有review_leave权限的成员可以下载,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Leave_attachment(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		var leave LeaveRequest
+		if err := _GetContextGroupDatabase(c).First(&leave, request.ID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 20, "message": "请假申请不存在"})
+			return
+		}
+		if leave.UserID != _GetContextUserID(c) && !_GetContextGroupPermissions(c).Has(CapReviewLeave) {
+			c.JSON(403, gin.H{"code": 3, "message": "权限不足"})
+			return
+		}
+		if leave.AttachmentName == "" {
+			c.JSON(400, gin.H{"code": 23, "message": "没有附件"})
+			return
+		}
+		attachment, err := os.ReadFile(_LeaveAttachmentPath(GlobalPath, _GetContextGroupID(c), leave.ID))
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "读取附件失败"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", leave.AttachmentName))
+		c.Data(200, leave.AttachmentType, attachment)
+	}
+}
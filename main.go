@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -21,10 +22,21 @@ var (
 	DataPath = "data"
 	LogPath  = "logs"
 	GinPort  = ":8080"
+
+	WXAPIBaseURL = "https://api.weixin.qq.com"
+	WXAPITimeout = 5 * time.Second
+)
+
+var (
+	// JWTKeyringPath jwt密钥环文件路径,为空时使用DataPath下的jwt_keyring.json
+	JWTKeyringPath = ""
+	// JWTKeyringReloadInterval 检查密钥环文件是否被修改的间隔
+	JWTKeyringReloadInterval = time.Minute
 )
 
 var (
-	GlobalDatabase *gorm.DB = nil
+	GlobalDatabase   *gorm.DB    = nil
+	GlobalJWTKeyring *JWTKeyring = nil
 )
 
 func main() {
@@ -58,6 +70,12 @@ func main() {
 
 	log.SeDataEraserCags(log.Ldate | log.Ltime | log.Lshortfile)
 
+	// 带参数运行时执行管理员子命令而不是启动服务
+	if len(os.Args) > 1 {
+		f.Close()
+		os.Exit(RunCommand(os.Args[1:]))
+	}
+
 	gin.SetMode(gin.DebugMode)
 	r := gin.Default()
 
@@ -65,16 +83,228 @@ func main() {
 	r.POST("/login_account_password", Login_account_password(GlobalDatabase))
 
 	// 用户登录接口(微信)
-	r.POST("/login_wx", Login_wx(GlobalDatabase, WXAppID, WXAppSecret))
+	wxClient := NewHTTPWXClient(WXAPIBaseURL, WXAppID, WXAppSecret, WXAPITimeout)
+	r.POST("/login_wx", Login_wx(GlobalDatabase, wxClient))
+
+	// 用户注册接口(账号密码)
+	r.POST("/register", Register(GlobalDatabase))
+
+	// 刷新Token接口(使用RefreshToken,无需携带Authorization)
+	r.POST("/refresh_token", Refresh_token(GlobalDatabase))
+
+	// 以下接口需要在请求头中携带 Authorization: Bearer <Token>
+	authorized := r.Group("/")
+	authorized.Use(AuthMiddleware(GlobalDatabase))
+
+	// 用户修改密码接口
+	authorized.POST("/change_password", Change_password(GlobalDatabase))
 
 	// 用户获取个人信息接口
-	r.POST("/userinfo", Userinfo(GlobalDatabase))
+	authorized.POST("/userinfo", Userinfo(GlobalDatabase))
 
 	// 用户修改个人信息接口
-	r.POST("/updateuserinfo", Updateuserinfo(GlobalDatabase))
+	authorized.POST("/updateuserinfo", Updateuserinfo(GlobalDatabase))
 
 	// 用户注销登陆接口
-	r.POST("/logout", Logout(GlobalDatabase))
+	authorized.POST("/logout", Logout(GlobalDatabase))
+
+	// 会话列表接口
+	authorized.POST("/sessions", Sessions(GlobalDatabase))
+
+	// 撤销单个会话接口
+	authorized.POST("/revoke_session", Revoke_session(GlobalDatabase))
+
+	// 在所有设备上注销接口
+	authorized.POST("/logout_all", Logout_all(GlobalDatabase))
+
+	// 用户绑定微信接口
+	authorized.POST("/bind_wx", Bind_wx(GlobalDatabase, wxClient))
+
+	// 用户解绑微信接口
+	authorized.POST("/unbind_wx", Unbind_wx(GlobalDatabase))
+
+	// 提交创建组织申请接口
+	authorized.POST("/create_group_request", Create_group_request(GlobalDatabase))
+
+	// 查看自己提交的创建组织申请接口
+	authorized.POST("/my_create_group_requests", My_create_group_requests(GlobalDatabase))
+
+	// 通过GroupCode加入组织接口
+	authorized.POST("/join_group", Join_group(GlobalDatabase, DataPath))
+
+	// 用学号及认领码认领导入名单时创建的占位用户接口
+	authorized.POST("/claim_placeholder", Claim_placeholder(GlobalDatabase, DataPath))
+
+	// 查看自己加入的组织接口
+	authorized.POST("/my_groups", My_groups(GlobalDatabase, DataPath))
+
+	// 以下组织接口由GroupMiddleware按请求体中的GroupID检查组织内权限
+
+	// 查看组织成员接口
+	authorized.POST("/group_members", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Group_members(GlobalDatabase))
+
+	// 退出组织接口
+	authorized.POST("/leave_group", GroupMiddleware(GlobalDatabase, DataPath, ""), Leave_group(DataPath))
+
+	// 组织管理员查看加入申请接口
+	authorized.POST("/join_requests", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Join_requests())
+
+	// 组织管理员审核加入申请接口
+	authorized.POST("/review_join_request", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Review_join_request(DataPath))
+
+	// 组织管理员修改成员权限接口
+	authorized.POST("/set_member_permissions", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Set_member_permissions(DataPath))
+
+	// 组织管理员移除成员接口
+	authorized.POST("/remove_member", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Remove_member(DataPath))
+
+	// 组织管理员按学号导入名单接口
+	authorized.POST("/import_roster", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Import_roster(GlobalDatabase, DataPath))
+
+	// 组织管理员为占位用户生成认领码接口
+	authorized.POST("/issue_claim_code", GroupMiddleware(GlobalDatabase, DataPath, CapManageMembers), Issue_claim_code(GlobalDatabase, DataPath))
+
+	// 组织管理员修改组织设置接口
+	authorized.POST("/update_group_setting", GroupMiddleware(GlobalDatabase, DataPath, CapManageGroup), Update_group_setting())
+
+	// 安排会议接口
+	authorized.POST("/create_meeting", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Create_meeting(DataPath))
+
+	// 会议列表接口
+	authorized.POST("/meetings", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Meetings())
+
+	// 修改会议接口
+	authorized.POST("/update_meeting", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Update_meeting())
+
+	// 取消会议接口
+	authorized.POST("/cancel_meeting", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Cancel_meeting())
+
+	// 安排重复会议接口
+	authorized.POST("/create_meeting_series", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Create_meeting_series(DataPath))
+
+	// 重复会议列表接口
+	authorized.POST("/meeting_series", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Meeting_series())
+
+	// 修改整个重复会议接口
+	authorized.POST("/update_meeting_series", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Update_meeting_series(DataPath))
+
+	// 取消整个重复会议接口
+	authorized.POST("/cancel_meeting_series", GroupMiddleware(GlobalDatabase, DataPath, CapManageMeetings), Cancel_meeting_series())
+
+	// 发起签到接口
+	authorized.POST("/start_sign", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Start_sign(DataPath))
+
+	// 提前关闭签到接口
+	authorized.POST("/close_sign", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Close_sign(DataPath))
+
+	// 延长签到接口
+	authorized.POST("/extend_sign", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Extend_sign(DataPath))
+
+	// 获取二维码签到当前二维码内容接口
+	authorized.POST("/sign_qr_code", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Sign_qr_code(DataPath))
+
+	// 获取二维码签到当前二维码图片接口
+	authorized.POST("/sign_qr_image", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Sign_qr_image(DataPath))
+
+	// 查看PIN签到当前PIN接口
+	authorized.POST("/sign_pin", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Sign_pin(DataPath))
+
+	// 重新生成PIN接口
+	authorized.POST("/regenerate_sign_pin", GroupMiddleware(GlobalDatabase, DataPath, CapStartSign), Regenerate_sign_pin(DataPath))
+
+	// 会议签到列表接口
+	authorized.POST("/signs", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Signs(DataPath))
+
+	// 成员签到接口
+	authorized.POST("/sign_in", GroupMiddleware(GlobalDatabase, DataPath, CapSignIn), Sign_in(DataPath))
+
+	// 签到记录及位置接口
+	authorized.POST("/sign_records", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Sign_records(DataPath))
+
+	// 会议考勤接口
+	authorized.POST("/attendance", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Attendance(GlobalDatabase, DataPath))
+
+	// 查看自己的考勤接口
+	authorized.POST("/my_attendance", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), My_attendance(DataPath))
+
+	// 组织考勤统计接口
+	authorized.POST("/attendance_stats", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Attendance_stats(GlobalDatabase, DataPath))
+
+	// 成员考勤统计接口
+	authorized.POST("/member_attendance_stats", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Member_attendance_stats(DataPath))
+
+	// 查看自己的考勤统计接口
+	authorized.POST("/my_attendance_stats", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), My_attendance_stats(DataPath))
+
+	// 导出考勤表接口
+	authorized.POST("/export_attendance", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Export_attendance(GlobalDatabase, DataPath))
+
+	// 组织者手动修改考勤接口
+	authorized.POST("/override_attendance", GroupMiddleware(GlobalDatabase, DataPath, CapEditAttendance), Override_attendance(DataPath))
+
+	// 考勤修改记录接口
+	authorized.POST("/attendance_audit_log", GroupMiddleware(GlobalDatabase, DataPath, CapViewAttendance), Attendance_audit_log(DataPath))
+
+	// 查看自己的考勤修改记录接口
+	authorized.POST("/my_attendance_audit_log", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), My_attendance_audit_log(DataPath))
+
+	// 提交考勤申诉接口
+	authorized.POST("/appeal_attendance", GroupMiddleware(GlobalDatabase, DataPath, CapSignIn), Appeal_attendance(DataPath))
+
+	// 查看自己的考勤申诉接口
+	authorized.POST("/my_attendance_appeals", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), My_attendance_appeals())
+
+	// 撤回考勤申诉接口
+	authorized.POST("/cancel_attendance_appeal", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Cancel_attendance_appeal())
+
+	// 组织者查看考勤申诉接口
+	authorized.POST("/attendance_appeals", GroupMiddleware(GlobalDatabase, DataPath, CapEditAttendance), Attendance_appeals())
+
+	// 组织者处理考勤申诉接口
+	authorized.POST("/review_attendance_appeal", GroupMiddleware(GlobalDatabase, DataPath, CapEditAttendance), Review_attendance_appeal(DataPath))
+
+	// 提交请假申请接口
+	authorized.POST("/request_leave", GroupMiddleware(GlobalDatabase, DataPath, CapSignIn), Request_leave(DataPath))
+
+	// 查看自己的请假申请接口
+	authorized.POST("/my_leave_requests", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), My_leave_requests())
+
+	// 撤回请假申请接口
+	authorized.POST("/cancel_leave_request", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Cancel_leave_request())
+
+	// 下载请假附件接口
+	authorized.POST("/leave_attachment", GroupMiddleware(GlobalDatabase, DataPath, CapViewGroup), Leave_attachment(DataPath))
+
+	// 组织者查看请假申请接口
+	authorized.POST("/leave_requests", GroupMiddleware(GlobalDatabase, DataPath, CapReviewLeave), Leave_requests())
+
+	// 组织者审核请假申请接口
+	authorized.POST("/review_leave_request", GroupMiddleware(GlobalDatabase, DataPath, CapReviewLeave), Review_leave_request())
+
+	// 随机点名接口
+	authorized.POST("/roll_call", GroupMiddleware(GlobalDatabase, DataPath, CapRollCall), Roll_call(GlobalDatabase, DataPath))
+
+	// 登记点名应答情况接口
+	authorized.POST("/record_roll_call", GroupMiddleware(GlobalDatabase, DataPath, CapRollCall), Record_roll_call(DataPath))
+
+	// 点名记录接口
+	authorized.POST("/roll_calls", GroupMiddleware(GlobalDatabase, DataPath, CapRollCall), Roll_calls(GlobalDatabase, DataPath))
+
+	// 以下接口需要站点管理员权限
+	siteAdmin := authorized.Group("/")
+	siteAdmin.Use(SiteAdminMiddleware(GlobalDatabase))
+
+	// 站点管理员查看创建组织申请接口
+	siteAdmin.POST("/create_group_requests", Create_group_requests(GlobalDatabase))
+
+	// 站点管理员审核创建组织申请接口
+	siteAdmin.POST("/review_create_group_request", Review_create_group_request(GlobalDatabase, DataPath))
+
+	// 后台定期检查密钥环文件,rotate-jwt-key后无需重启
+	StartJWTKeyringWatcher(GlobalJWTKeyring, JWTKeyringReloadInterval)
+
+	// 后台定期清理过期的会话
+	StartTokenSweeper(GlobalDatabase, TokenSweepInterval)
 
 	err = r.Run(GinPort)
 	if err != nil {
@@ -105,10 +335,27 @@ func init() {
 		LogPath = envLogPath
 	}
 
+	if envJWTKeyringPath := os.Getenv("JWTKeyringPath"); envJWTKeyringPath != "" {
+		JWTKeyringPath = envJWTKeyringPath
+	}
+
 	if envGinPort := os.Getenv("GinPort"); envGinPort != "" {
 		GinPort = envGinPort
 	}
 
+	// CI中可以指向本地的假jscode2session服务器
+	if envWXAPIBaseURL := os.Getenv("WXAPIBaseURL"); envWXAPIBaseURL != "" {
+		WXAPIBaseURL = envWXAPIBaseURL
+	}
+
+	if envWXAPITimeout := os.Getenv("WXAPITimeout"); envWXAPITimeout != "" {
+		timeout, err := time.ParseDuration(envWXAPITimeout)
+		if err != nil {
+			panic("failed to parse WXAPITimeout")
+		}
+		WXAPITimeout = timeout
+	}
+
 	// 调用子模块函数初始化
 
 	// 全局唯一的资源(必须加载)
@@ -119,6 +366,14 @@ func init() {
 		panic("failed at init()")
 	}
 
+	if JWTKeyringPath == "" {
+		JWTKeyringPath = DataPath + "/jwt_keyring.json"
+	}
+	GlobalJWTKeyring, err = LoadJWTKeyring(JWTKeyringPath, JWTSecretKey)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load jwt keyring: %v", err))
+	}
+
 	log.Println("Initialize global Resource successfully......")
 
 }
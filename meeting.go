@@ -9,10 +9,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/glebarez/sqlite"
-	"go.starlark.net/lib/time"
 	"gorm.io/gorm"
 )
 
@@ -27,12 +30,67 @@ type Sign struct {
 	ID      uint
 	BeginAt time.Time
 	EndAt   time.Time
+	// Closed 组织者提前关闭的签到不能再延长
+	Closed bool
+	// Mode 签到方式 plain(直接签到)/qr(扫描组织者屏幕上的动态二维码)/pin(输入组织者念出的数字PIN)
+	Mode string `gorm:"default:plain"`
+	// Secret 计算动态二维码的HMAC密钥
+	Secret string `json:"-"`
+	// QRInterval 动态二维码的刷新间隔(秒)
+	QRInterval int
+	// PIN 数字PIN,只通过/sign_pin返回给组织者
+	PIN string `json:"-"`
+	// Geofence 签到围栏,未设置时使用会议的围栏
+	Geofence  Geofence `gorm:"embedded;embeddedPrefix:fence_"`
+	CreatedBy uint
+	CreatedAt int64
 }
 
+// 签到方式
+const (
+	// SignModePlain 签到期间直接签到
+	SignModePlain = "plain"
+	// SignModeQR 必须提交组织者屏幕上当前有效的二维码内容
+	SignModeQR = "qr"
+	// SignModePIN 必须提交组织者念出的数字PIN
+	SignModePIN = "pin"
+)
+
 // SignatureBook 用户签到数据库对象,记录用户是否签到
 type SignatureBook struct {
-	UserID uint
-	SignID uint
+	UserID uint `gorm:"uniqueIndex:idx_signature_user_sign"`
+	SignID uint `gorm:"uniqueIndex:idx_signature_user_sign;index"`
+	// SignedAt 签到时间,记录签到时间之前的签到为0
+	SignedAt int64
+	// Latitude Longitude Accuracy 签到时客户端上报的位置及定位精度(米),没有上报时为空
+	Latitude  *float64
+	Longitude *float64
+	Accuracy  *float64
+}
+
+// 签到的状态
+const (
+	// SignStatusScheduled 尚未开始
+	SignStatusScheduled = "scheduled"
+	// SignStatusOpen 正在进行
+	SignStatusOpen = "open"
+	// SignStatusClosed 已结束或已被关闭
+	SignStatusClosed = "closed"
+)
+
+// @title         Status
+// @description   计算签到在某一时刻的状态
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Now              time.Time           "当前时间"
+// @return        status           string              "scheduled/open/closed"
+func (sign Sign) Status(Now time.Time) string {
+	if sign.Closed || !Now.Before(sign.EndAt) {
+		return SignStatusClosed
+	}
+	if Now.Before(sign.BeginAt) {
+		return SignStatusScheduled
+	}
+	return SignStatusOpen
 }
 
 // 每次要对会议数据库修改时必须先动态加载数据库
@@ -63,15 +121,690 @@ func InitMeeting(GlobalPath string, GroupID uint, MeetingID uint, SafeMode bool)
 		log.Println("Global directory already exists!")
 	}
 
-	MeetingDatabase, err := gorm.Open(sqlite.Open(fmt.Sprintf(MeetingDataPath+"/database.db", GlobalPath, GroupID, MeetingID)), &gorm.Config{})
+	MeetingDatabase, err := gorm.Open(sqlite.Open(MeetingDataPath+"/database.db"), &gorm.Config{})
 	if err != nil {
 		return nil, errors.New("failed to connect database")
 	}
 	if SafeMode {
-		err = MeetingDatabase.AutoMigrate(&MettingParticipants{}, &Sign{}, &SignatureBook{})
+		err = MeetingDatabase.AutoMigrate(&MettingParticipants{}, &Sign{}, &SignatureBook{}, &PINAttempt{}, &RollCall{}, &AttendanceOverride{}, &AttendanceAudit{})
 		if err != nil {
 			return nil, errors.New("failed to AutoMigrate database")
 		}
 	}
 	return MeetingDatabase, nil
 }
+
+// 会议列表分页
+const (
+	// MeetingPageSize 默认每页会议数
+	MeetingPageSize = 20
+	// MeetingMaxPageSize 每页最多会议数
+	MeetingMaxPageSize = 100
+)
+
+// @title         normalizePage
+// @description   规范化分页参数,页码从1开始
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Page             int                 "页码"
+// @param         PageSize         int                 "每页数量"
+// @param         DefaultSize      int                 "默认每页数量"
+// @param         MaxSize          int                 "每页最多数量"
+// @return        page             int                 "规范化后的页码"
+// @return        pageSize         int                 "规范化后的每页数量"
+func normalizePage(Page int, PageSize int, DefaultSize int, MaxSize int) (int, int) {
+	if Page < 1 {
+		Page = 1
+	}
+	if PageSize < 1 {
+		PageSize = DefaultSize
+	} else if PageSize > MaxSize {
+		PageSize = MaxSize
+	}
+	return Page, PageSize
+}
+
+// @title         _GetMeeting
+// @description   从组织数据库读取会议信息
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         MeetingID                             uint                "会议ID"
+// @return        meeting                               MeetingInfo         "会议信息"
+// @return        err                                   error               "可能存在的错误"
+func _GetMeeting(GroupDatabase *gorm.DB, MeetingID uint) (MeetingInfo, error) {
+	var meeting MeetingInfo
+	err := GroupDatabase.First(&meeting, MeetingID).Error
+	return meeting, err
+}
+
+// @title         Create_meeting
+// @description   在组织内安排会议并初始化会议数据库,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Create_meeting(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			BeginAt            time.Time
+			EndAt              time.Time
+			MeetingDescription string
+			Geofence           Geofence
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.BeginAt.IsZero() || request.EndAt.IsZero() {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if !request.EndAt.After(request.BeginAt) {
+			c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+			return
+		}
+		if err := request.Geofence.Validate(); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+
+		// sqlite按字符串比较时间,统一转换到服务器时区再存储
+		meeting := MeetingInfo{
+			BeginAt:            request.BeginAt.Local(),
+			EndAt:              request.EndAt.Local(),
+			MeetingDescription: request.MeetingDescription,
+			Geofence:           request.Geofence,
+			CreatedBy:          _GetContextUserID(c),
+			CreatedAt:          time.Now().Unix(),
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+		if err := GroupDatabase.Create(&meeting).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		MeetingDatabase, err := InitMeeting(GlobalPath, _GetContextGroupID(c), meeting.ID, true)
+		if err != nil {
+			GroupDatabase.Delete(&meeting)
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		_CloseDatabase(MeetingDatabase)
+		c.JSON(200, gin.H{"code": 0, "message": "创建会议成功", "data": meeting})
+	}
+}
+
+// @title         Meetings
+// @description   分页列出组织内即将进行(含进行中)或已经结束的会议,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Meetings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// Scope upcoming(默认)按开始时间升序,past按开始时间降序
+			Scope           string
+			IncludeCanceled bool
+			Page            int
+			PageSize        int
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		page, pageSize := normalizePage(request.Page, request.PageSize, MeetingPageSize, MeetingMaxPageSize)
+
+		query := _GetContextGroupDatabase(c).Model(&MeetingInfo{})
+		now := time.Now()
+		switch request.Scope {
+		case "", "upcoming":
+			query = query.Where("end_at >= ?", now).Order("begin_at ASC")
+		case "past":
+			query = query.Where("end_at < ?", now).Order("begin_at DESC")
+		default:
+			c.JSON(400, gin.H{"code": 1, "message": "Scope只能是upcoming或past"})
+			return
+		}
+		if !request.IncludeCanceled {
+			query = query.Where("canceled = ?", false)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		meetings := []MeetingInfo{}
+		if err := query.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&meetings).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取会议列表成功", "data": meetings, "Total": total, "Page": page, "PageSize": pageSize})
+	}
+}
+
+// @title         Update_meeting
+// @description   修改会议时间或描述,已取消的会议不能修改,重复会议中的一次被修改后不再跟随整个重复会议的修改,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Update_meeting() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID          uint
+			BeginAt            *time.Time
+			EndAt              *time.Time
+			MeetingDescription *string
+			// Geofence 传入空对象{}时取消围栏
+			Geofence *Geofence
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		meeting, err := _GetMeeting(GroupDatabase, request.MeetingID)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "会议不存在"})
+			return
+		}
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+
+		updateData := make(map[string]interface{})
+		if request.BeginAt != nil {
+			meeting.BeginAt = request.BeginAt.Local()
+			updateData["BeginAt"] = meeting.BeginAt
+		}
+		if request.EndAt != nil {
+			meeting.EndAt = request.EndAt.Local()
+			updateData["EndAt"] = meeting.EndAt
+		}
+		if request.MeetingDescription != nil {
+			meeting.MeetingDescription = *request.MeetingDescription
+			updateData["MeetingDescription"] = meeting.MeetingDescription
+		}
+		if request.Geofence != nil {
+			if err := request.Geofence.Validate(); err != nil {
+				c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+				return
+			}
+			meeting.Geofence = *request.Geofence
+			for column, value := range geofenceColumns(meeting.Geofence) {
+				updateData[column] = value
+			}
+		}
+		if !meeting.EndAt.After(meeting.BeginAt) {
+			c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+			return
+		}
+		if len(updateData) > 0 {
+			if meeting.SeriesID != 0 {
+				meeting.Detached = true
+				updateData["Detached"] = true
+			}
+			if err := GroupDatabase.Model(&MeetingInfo{}).Where("id = ?", meeting.ID).Updates(updateData).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "修改会议成功", "data": meeting})
+	}
+}
+
+// @title         Cancel_meeting
+// @description   取消会议,保留会议记录,重复会议中的一次被取消后修改整个重复会议时也不会恢复,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Cancel_meeting() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		meeting, err := _GetMeeting(GroupDatabase, request.MeetingID)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 4, "message": "会议不存在"})
+			return
+		}
+		updateData := map[string]interface{}{"Canceled": true}
+		if meeting.SeriesID != 0 {
+			updateData["Detached"] = true
+		}
+		result := GroupDatabase.Model(&MeetingInfo{}).Where("id = ? AND canceled = ?", request.MeetingID, false).Updates(updateData)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已取消会议"})
+	}
+}
+
+// @title         _OpenMeeting
+// @description   确认会议属于GroupMiddleware加载的组织后加载会议数据库,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         MeetingID                             uint                "会议ID"
+// @return        meeting                               MeetingInfo         "会议信息"
+// @return        MeetingDatabase                       *gorm.DB            "会议数据库,用完后需要_CloseDatabase"
+// @return        ok                                    bool                "是否成功"
+func _OpenMeeting(c *gin.Context, GlobalPath string, MeetingID uint) (MeetingInfo, *gorm.DB, bool) {
+	meeting, err := _GetMeeting(_GetContextGroupDatabase(c), MeetingID)
+	if err != nil {
+		c.JSON(400, gin.H{"code": 4, "message": "会议不存在"})
+		return MeetingInfo{}, nil, false
+	}
+	MeetingDatabase, err := InitMeeting(GlobalPath, _GetContextGroupID(c), MeetingID, true)
+	if err != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return MeetingInfo{}, nil, false
+	}
+	return meeting, MeetingDatabase, true
+}
+
+// @title         Start_sign
+// @description   在会议中发起签到,可以立即开始或指定开始时间,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Start_sign(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			// BeginAt 为空时立即开始
+			BeginAt *time.Time
+			// EndAt 和Minutes二选一,EndAt优先
+			EndAt   *time.Time
+			Minutes int
+			// Mode 签到方式,为空时为plain
+			Mode string
+			// QRInterval 动态二维码的刷新间隔(秒),为0时使用默认值
+			QRInterval int
+			// PINLength PIN的位数,为0时使用默认值
+			PINLength int
+			// Geofence 签到围栏,不设置时使用会议的围栏
+			Geofence Geofence
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if err := request.Geofence.Validate(); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+		sign := Sign{Mode: request.Mode, Geofence: request.Geofence}
+		switch request.Mode {
+		case "", SignModePlain:
+			sign.Mode = SignModePlain
+		case SignModeQR:
+			sign.Secret = randomHex(32)
+			sign.QRInterval = request.QRInterval
+			if sign.QRInterval == 0 {
+				sign.QRInterval = SignQRDefaultInterval
+			}
+			if sign.QRInterval < SignQRMinInterval || sign.QRInterval > SignQRMaxInterval {
+				c.JSON(400, gin.H{"code": 1, "message": fmt.Sprintf("QRInterval必须在%d到%d秒之间", SignQRMinInterval, SignQRMaxInterval)})
+				return
+			}
+		case SignModePIN:
+			if request.PINLength == 0 {
+				request.PINLength = SignPINDefaultLength
+			}
+			if request.PINLength < SignPINMinLength || request.PINLength > SignPINMaxLength {
+				c.JSON(400, gin.H{"code": 1, "message": fmt.Sprintf("PINLength必须在%d到%d之间", SignPINMinLength, SignPINMaxLength)})
+				return
+			}
+			sign.PIN = randomString(signPINAlphabet, request.PINLength)
+		default:
+			c.JSON(400, gin.H{"code": 1, "message": "未知的签到方式"})
+			return
+		}
+
+		now := time.Now()
+		beginAt := now
+		if request.BeginAt != nil && request.BeginAt.After(now) {
+			beginAt = request.BeginAt.Local()
+		}
+		var endAt time.Time
+		if request.EndAt != nil {
+			endAt = request.EndAt.Local()
+		} else if request.Minutes > 0 {
+			endAt = beginAt.Add(time.Duration(request.Minutes) * time.Minute)
+		} else {
+			c.JSON(400, gin.H{"code": 1, "message": "必须指定EndAt或Minutes"})
+			return
+		}
+		if !endAt.After(beginAt) {
+			c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+
+		sign.BeginAt = beginAt
+		sign.EndAt = endAt
+		sign.CreatedBy = _GetContextUserID(c)
+		sign.CreatedAt = now.Unix()
+		if err := MeetingDatabase.Create(&sign).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		response := gin.H{"code": 0, "message": "发起签到成功", "data": sign, "Status": sign.Status(now)}
+		if sign.Mode == SignModePIN {
+			response["PIN"] = sign.PIN
+		}
+		c.JSON(200, response)
+	}
+}
+
+// @title         Close_sign
+// @description   提前关闭签到,正在进行的签到结束时间改为现在,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Close_sign(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+		now := time.Now()
+		if sign.Status(now) == SignStatusClosed {
+			c.JSON(400, gin.H{"code": 9, "message": "签到已结束"})
+			return
+		}
+
+		updateData := map[string]interface{}{"Closed": true}
+		if sign.Status(now) == SignStatusOpen {
+			updateData["EndAt"] = now
+		}
+		if err := MeetingDatabase.Model(&sign).Updates(updateData).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已关闭签到", "data": sign})
+	}
+}
+
+// @title         Extend_sign
+// @description   延长签到的结束时间,已自然结束的签到延长后重新开放,被关闭的签到不能延长,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Extend_sign(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			// EndAt 和Minutes(在原结束时间上延长)二选一,EndAt优先
+			EndAt   *time.Time
+			Minutes int
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+		if sign.Closed {
+			c.JSON(400, gin.H{"code": 9, "message": "签到已被关闭"})
+			return
+		}
+
+		var endAt time.Time
+		if request.EndAt != nil {
+			endAt = request.EndAt.Local()
+		} else if request.Minutes > 0 {
+			endAt = sign.EndAt.Add(time.Duration(request.Minutes) * time.Minute)
+		} else {
+			c.JSON(400, gin.H{"code": 1, "message": "必须指定EndAt或Minutes"})
+			return
+		}
+		now := time.Now()
+		if !endAt.After(sign.EndAt) || !endAt.After(now) {
+			c.JSON(400, gin.H{"code": 1, "message": "新的结束时间必须晚于原结束时间及当前时间"})
+			return
+		}
+
+		if err := MeetingDatabase.Model(&sign).Update("end_at", endAt).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已延长签到", "data": sign, "Status": sign.Status(now)})
+	}
+}
+
+// @title         Signs
+// @description   列出会议的所有签到及当前用户是否已签到,有view_attendance权限时同时返回签到人数,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Signs(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var signs []Sign
+		if err := MeetingDatabase.Order("begin_at ASC").Find(&signs).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		var signedIDs []uint
+		if err := MeetingDatabase.Model(&SignatureBook{}).Where("user_id = ?", _GetContextUserID(c)).Pluck("sign_id", &signedIDs).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		signed := make(map[uint]bool)
+		for _, signID := range signedIDs {
+			signed[signID] = true
+		}
+		viewAttendance := _GetContextGroupPermissions(c).Has(CapViewAttendance)
+
+		now := time.Now()
+		data := make([]gin.H, 0, len(signs))
+		for _, sign := range signs {
+			item := gin.H{
+				"ID":      sign.ID,
+				"BeginAt": sign.BeginAt,
+				"EndAt":   sign.EndAt,
+				"Closed":  sign.Closed,
+				"Mode":    sign.Mode,
+				"Status":  sign.Status(now),
+				"Signed":  signed[sign.ID],
+				// Geofence 生效的围栏,客户端据此决定是否需要定位
+				"Geofence": signGeofence(meeting, sign),
+			}
+			if viewAttendance {
+				var count int64
+				MeetingDatabase.Model(&SignatureBook{}).Where("sign_id = ?", sign.ID).Count(&count)
+				item["SignedCount"] = count
+			}
+			data = append(data, item)
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取签到列表成功", "data": data})
+	}
+}
+
+// @title         Sign_records
+// @description   列出某次签到的签到记录及上报的位置,设置了围栏时同时返回到围栏的距离,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sign_records(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+		var books []SignatureBook
+		if err := MeetingDatabase.Where("sign_id = ?", sign.ID).Order("user_id ASC").Find(&books).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+
+		fence := signGeofence(meeting, sign)
+		data := make([]gin.H, 0, len(books))
+		for _, book := range books {
+			item := gin.H{
+				"UserID":    book.UserID,
+				"Latitude":  book.Latitude,
+				"Longitude": book.Longitude,
+				"Accuracy":  book.Accuracy,
+			}
+			if fence.Enabled() && book.Latitude != nil && book.Longitude != nil {
+				item["Distance"] = math.Round(fence.Distance(*book.Latitude, *book.Longitude))
+			}
+			data = append(data, item)
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取签到记录成功", "data": data, "Geofence": fence})
+	}
+}
+
+// @title         Sign_in
+// @description   成员签到,不是成员、签到未开始或已结束、二维码或PIN无效、重复签到分别返回不同的错误码,需要先经过GroupMiddleware(CapSignIn)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sign_in(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			// QRCode 二维码签到时扫描到的二维码内容
+			QRCode string
+			// PIN PIN签到时输入的PIN
+			PIN string
+			// Latitude Longitude Accuracy 客户端定位结果,设置了围栏的签到必须上报
+			Latitude  *float64
+			Longitude *float64
+			Accuracy  *float64
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+		now := time.Now()
+		switch sign.Status(now) {
+		case SignStatusScheduled:
+			c.JSON(400, gin.H{"code": 8, "message": "签到尚未开始"})
+			return
+		case SignStatusClosed:
+			c.JSON(400, gin.H{"code": 9, "message": "签到已结束"})
+			return
+		}
+		if sign.Mode == SignModeQR && !verifySignQRPayload(_GetContextGroupID(c), meeting.ID, sign, request.QRCode, now) {
+			c.JSON(400, gin.H{"code": 11, "message": "二维码无效或已过期"})
+			return
+		}
+		if fence := signGeofence(meeting, sign); fence.Enabled() && !_CheckSignLocation(c, fence, request.Latitude, request.Longitude, request.Accuracy) {
+			return
+		}
+		if sign.Mode == SignModePIN && !_CheckSignPIN(c, MeetingDatabase, sign, request.PIN) {
+			return
+		}
+
+		book := SignatureBook{UserID: _GetContextUserID(c), SignID: sign.ID}
+		result := MeetingDatabase.Where(book).
+			Attrs(SignatureBook{SignedAt: now.Unix(), Latitude: request.Latitude, Longitude: request.Longitude, Accuracy: request.Accuracy}).
+			FirstOrCreate(&book)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 10, "message": "已经签到"})
+			return
+		}
+		response := gin.H{"code": 0, "message": "签到成功"}
+		if setting, err := _GetGroupSetting(_GetContextGroupDatabase(c)); err == nil {
+			response["Attendance"] = classifySign(sign, &book, time.Duration(setting.LateGraceMinutes)*time.Minute, false, now)
+		}
+		c.JSON(200, response)
+	}
+}
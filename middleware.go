@@ -0,0 +1,178 @@
+// @Title       middleware.go
+// @Description 放置gin中间件(身份验证等)以及从gin上下文读取中间件结果的工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 中间件写入gin上下文的键
+const (
+	ContextUserID           = "UserID"
+	ContextToken            = "Token"
+	ContextGroupID          = "GroupID"
+	ContextGroupDatabase    = "GroupDatabase"
+	ContextGroupPermissions = "GroupPermissions"
+)
+
+// @title         AuthMiddleware
+// @description   身份验证中间件,从Authorization: Bearer读取Token,校验签名及有效期,并确认Token未被撤销
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库(存放Token表)"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func AuthMiddleware(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		tokenString = strings.TrimSpace(tokenString)
+		if !ok || tokenString == "" {
+			c.AbortWithStatusJSON(401, gin.H{"code": 1, "message": "缺少Token"})
+			return
+		}
+
+		claims, err := parseToken(tokenString, GlobalJWTKeyring)
+		if err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"code": 1, "message": "Token无效或已过期"})
+			return
+		}
+		// jwt解析出的数字均为float64
+		claimUserID, ok := claims["userid"].(float64)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"code": 1, "message": "Token无效或已过期"})
+			return
+		}
+
+		// 注销或修改密码时会删除Token,查不到即视为已撤销
+		var tokenRecord Token
+		if err := GlobalDatabase.Where("token = ?", tokenString).First(&tokenRecord).Error; err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"code": 1, "message": "Token已被撤销"})
+			return
+		}
+		if tokenRecord.UserID != uint(claimUserID) {
+			c.AbortWithStatusJSON(401, gin.H{"code": 1, "message": "Token无效或已过期"})
+			return
+		}
+
+		_TouchSession(GlobalDatabase, &tokenRecord)
+
+		c.Set(ContextUserID, tokenRecord.UserID)
+		c.Set(ContextToken, tokenRecord.Token)
+		c.Next()
+	}
+}
+
+// @title         SiteAdminMiddleware
+// @description   站点管理员中间件,只允许UserInfo.SiteAdmin为true的用户通过,必须放在AuthMiddleware之后
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func SiteAdminMiddleware(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user UserInfo
+		if err := GlobalDatabase.Select("id", "site_admin").First(&user, _GetContextUserID(c)).Error; err != nil || !user.SiteAdmin {
+			c.AbortWithStatusJSON(403, gin.H{"code": 1, "message": "需要站点管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// @title         GroupMiddleware
+// @description   组织权限中间件,从json请求体读取GroupID,确认当前用户是组织成员且拥有指定权限,并加载组织数据库,必须放在AuthMiddleware之后,之后的处理函数需要用c.ShouldBindBodyWith读取请求体
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @param         Capability                    string              "需要的细分权限,为空时只要求是组织成员"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func GroupMiddleware(GlobalDatabase *gorm.DB, GlobalPath string, Capability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			GroupID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.GroupID == 0 {
+			c.AbortWithStatusJSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if _, err := _GetGroupInfo(GlobalDatabase, request.GroupID); err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"code": 2, "message": "组织不存在"})
+			return
+		}
+
+		GroupDatabase, err := InitGroup(GlobalPath, request.GroupID, true)
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		defer _CloseDatabase(GroupDatabase)
+
+		permissions, err := _GetMemberPermissions(GroupDatabase, _GetContextUserID(c))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.AbortWithStatusJSON(403, gin.H{"code": 3, "message": "不是组织成员"})
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if Capability != "" && !permissions.Has(Capability) {
+			c.AbortWithStatusJSON(403, gin.H{"code": 3, "message": "权限不足"})
+			return
+		}
+
+		c.Set(ContextGroupID, request.GroupID)
+		c.Set(ContextGroupDatabase, GroupDatabase)
+		c.Set(ContextGroupPermissions, permissions)
+		c.Next()
+	}
+}
+
+// @title         _GetContextUserID
+// @description   读取AuthMiddleware写入的UserID,只能在AuthMiddleware之后调用
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @return        UserID           uint                "当前登陆的用户ID"
+func _GetContextUserID(c *gin.Context) uint {
+	return c.GetUint(ContextUserID)
+}
+
+// @title         _GetContextToken
+// @description   读取AuthMiddleware写入的Token,只能在AuthMiddleware之后调用
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @return        Token            string              "当前请求使用的Token"
+func _GetContextToken(c *gin.Context) string {
+	return c.GetString(ContextToken)
+}
+
+// @title         _GetContextGroupID
+// @description   读取GroupMiddleware写入的GroupID,只能在GroupMiddleware之后调用
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @return        GroupID          uint                "请求的组织ID"
+func _GetContextGroupID(c *gin.Context) uint {
+	return c.GetUint(ContextGroupID)
+}
+
+// @title         _GetContextGroupDatabase
+// @description   读取GroupMiddleware加载的组织数据库,请求结束后由中间件关闭,处理函数不要关闭它
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @return        GroupDatabase    *gorm.DB            "组织数据库"
+func _GetContextGroupDatabase(c *gin.Context) *gorm.DB {
+	return c.MustGet(ContextGroupDatabase).(*gorm.DB)
+}
+
+// @title         _GetContextGroupPermissions
+// @description   读取GroupMiddleware写入的当前用户在组织内的权限
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @return        permissions      GroupPermissions    "当前用户的权限"
+func _GetContextGroupPermissions(c *gin.Context) GroupPermissions {
+	return c.MustGet(ContextGroupPermissions).(GroupPermissions)
+}
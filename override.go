@@ -0,0 +1,258 @@
+// @Title       override.go
+// @Description 放置组织者手动修改考勤及考勤修改记录的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// AttendanceOverride 手动修改的考勤gorm对象,存在时代替根据签到记录计算的状态
+type AttendanceOverride struct {
+	UserID    uint `gorm:"uniqueIndex:idx_override_user_sign"`
+	SignID    uint `gorm:"uniqueIndex:idx_override_user_sign"`
+	Status    string
+	UpdatedBy uint
+	UpdatedAt int64
+}
+
+// AttendanceAudit 考勤修改记录gorm对象,每次手动修改考勤都追加一行,不会删除
+type AttendanceAudit struct {
+	ID     uint
+	UserID uint `gorm:"index"`
+	SignID uint `gorm:"index"`
+	// PreviousStatus 修改前的考勤状态(可能是计算出来的)
+	PreviousStatus string
+	// Status 修改后的考勤状态,恢复自动计算时为auto
+	Status    string
+	Reason    string
+	ChangedBy uint
+	ChangedAt int64
+}
+
+// AttendanceOverrideAuto 撤销手动修改,恢复根据签到记录计算
+const AttendanceOverrideAuto = "auto"
+
+// overridableStatuses 可以手动设置的单次签到考勤状态
+var overridableStatuses = map[string]bool{
+	AttendancePresent: true, AttendanceLate: true, AttendanceAbsent: true, AttendanceExcused: true,
+	AttendanceOverrideAuto: true,
+}
+
+// @title         _SignAttendanceStatus
+// @description   计算成员在某次签到中当前的考勤状态(包括请假及手动修改),尚未开始的签到或者不在考勤中的用户为pending
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         meeting                               MeetingInfo         "会议"
+// @param         UserID                                uint                "用户ID"
+// @param         SignID                                uint                "签到ID"
+// @param         Now                                   time.Time           "当前时间"
+// @return        status                                string              "考勤状态"
+// @return        err                                   error               "可能存在的错误"
+func _SignAttendanceStatus(GroupDatabase *gorm.DB, MeetingDatabase *gorm.DB, meeting MeetingInfo, UserID uint, SignID uint, Now time.Time) (string, error) {
+	excused, err := _ExcusedUserIDs(GroupDatabase, meeting)
+	if err != nil {
+		return "", err
+	}
+	attendance, err := _MeetingAttendance(GroupDatabase, MeetingDatabase, meeting, excused, Now)
+	if err != nil {
+		return "", err
+	}
+	for _, member := range attendance {
+		if member.UserID != UserID {
+			continue
+		}
+		for _, item := range member.Signs {
+			if item.SignID == SignID {
+				return item.Status, nil
+			}
+		}
+	}
+	return AttendancePending, nil
+}
+
+// @title         _OverrideAttendance
+// @description   手动修改成员在某次签到中的考勤并追加修改记录,Status为auto时撤销手动修改
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         meeting                               MeetingInfo         "会议"
+// @param         UserID                                uint                "用户ID"
+// @param         SignID                                uint                "签到ID"
+// @param         Status                                string              "修改后的考勤状态"
+// @param         Reason                                string              "修改原因"
+// @param         ChangedBy                             uint                "修改人ID"
+// @return        audit                                 AttendanceAudit     "这次修改的记录"
+// @return        err                                   error               "撤销时没有手动修改过为gorm.ErrRecordNotFound"
+func _OverrideAttendance(GroupDatabase *gorm.DB, MeetingDatabase *gorm.DB, meeting MeetingInfo, UserID uint, SignID uint, Status string, Reason string, ChangedBy uint) (AttendanceAudit, error) {
+	now := time.Now()
+	previous, err := _SignAttendanceStatus(GroupDatabase, MeetingDatabase, meeting, UserID, SignID, now)
+	if err != nil {
+		return AttendanceAudit{}, err
+	}
+	audit := AttendanceAudit{
+		UserID:         UserID,
+		SignID:         SignID,
+		PreviousStatus: previous,
+		Status:         Status,
+		Reason:         Reason,
+		ChangedBy:      ChangedBy,
+		ChangedAt:      now.Unix(),
+	}
+	err = MeetingDatabase.Transaction(func(tx *gorm.DB) error {
+		if Status == AttendanceOverrideAuto {
+			result := tx.Where("user_id = ? AND sign_id = ?", UserID, SignID).Delete(&AttendanceOverride{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		} else {
+			override := AttendanceOverride{UserID: UserID, SignID: SignID}
+			if err := tx.Where(override).
+				Assign(AttendanceOverride{Status: Status, UpdatedBy: ChangedBy, UpdatedAt: audit.ChangedAt}).
+				FirstOrCreate(&override).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&audit).Error
+	})
+	return audit, err
+}
+
+// @title         Override_attendance
+// @description   组织者手动修改成员在某次签到中的考勤(比如手机没电、网络故障),必须填写原因并记录修改前的状态,需要先经过GroupMiddleware(CapEditAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Override_attendance(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			UserID    uint
+			// Status present/late/absent/excused,auto表示撤销手动修改
+			Status string
+			Reason string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 || request.UserID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if !overridableStatuses[request.Status] {
+			c.JSON(400, gin.H{"code": 1, "message": "Status只能是present、late、absent、excused或auto"})
+			return
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+		if request.Reason == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "请填写修改原因"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+		var sign Sign
+		if err := MeetingDatabase.First(&sign, request.SignID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+		if _, err := _GetMemberPermissions(GroupDatabase, request.UserID); err != nil {
+			c.JSON(400, gin.H{"code": 24, "message": "该用户不是组织成员"})
+			return
+		}
+
+		audit, err := _OverrideAttendance(GroupDatabase, MeetingDatabase, meeting, request.UserID, sign.ID, request.Status, request.Reason, _GetContextUserID(c))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(400, gin.H{"code": 25, "message": "该考勤没有被手动修改过"})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已修改考勤", "data": audit})
+	}
+}
+
+// @title         Attendance_audit_log
+// @description   列出会议中的考勤修改记录,可以按成员筛选,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Attendance_audit_log(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			// UserID 为0时列出全部
+			UserID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		query := MeetingDatabase.Order("id DESC")
+		if request.UserID != 0 {
+			query = query.Where("user_id = ?", request.UserID)
+		}
+		var audits []AttendanceAudit
+		if err := query.Find(&audits).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取考勤修改记录成功", "data": audits})
+	}
+}
+
+// @title         My_attendance_audit_log
+// @description   成员查看自己在会议中被修改考勤的记录,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_attendance_audit_log(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var audits []AttendanceAudit
+		if err := MeetingDatabase.Where("user_id = ?", _GetContextUserID(c)).Order("id DESC").Find(&audits).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取考勤修改记录成功", "data": audits})
+	}
+}
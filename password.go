@@ -0,0 +1,197 @@
+// @Title       password.go
+// @Description 放置密码哈希、校验、用户名密码策略以及旧明文密码迁移的工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"gorm.io/gorm"
+)
+
+// argon2id 参数,修改后旧哈希会在用户下一次登陆时自动重新计算
+var (
+	PasswordHashTime    uint32 = 3
+	PasswordHashMemory  uint32 = 64 * 1024
+	PasswordHashThreads uint8  = 2
+	PasswordHashKeyLen  uint32 = 32
+	PasswordHashSaltLen        = 16
+)
+
+// passwordHashPrefix 数据库中哈希后的密码统一以此前缀开头,不带前缀的视为旧的明文密码
+const passwordHashPrefix = "$argon2id$"
+
+// @title         hashPassword
+// @description   使用argon2id加盐哈希密码,返回带算法及参数前缀的字符串
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         password         string              "明文密码"
+// @return        encoded          string              "形如$argon2id$v=19$m=65536,t=3,p=2$salt$hash的字符串"
+// @return        err              error               "可能存在的错误"
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, PasswordHashSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey([]byte(password), salt, PasswordHashTime, PasswordHashMemory, PasswordHashThreads, PasswordHashKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		passwordHashPrefix, argon2.Version, PasswordHashMemory, PasswordHashTime, PasswordHashThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// @title         verifyPassword
+// @description   校验密码,兼容旧的明文密码
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         encoded          string              "数据库中存储的密码"
+// @param         password         string              "用户提交的明文密码"
+// @return        match            bool                "密码是否正确"
+// @return        needRehash       bool                "存储的密码是否需要用当前参数重新哈希"
+// @return        err              error               "可能存在的错误(存储格式损坏)"
+func verifyPassword(encoded string, password string) (bool, bool, error) {
+	// 空密码表示该账号没有设置密码(比如仅微信登陆),任何输入都不匹配
+	if encoded == "" {
+		return false, false, nil
+	}
+
+	if !strings.HasPrefix(encoded, passwordHashPrefix) {
+		match := subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1
+		return match, true, nil
+	}
+
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$hash 按$切分后第一个元素为空
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, errors.New("密码哈希格式错误")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, err
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, err
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	if subtle.ConstantTimeCompare(hash, computed) != 1 {
+		return false, false, nil
+	}
+
+	needRehash := version != argon2.Version ||
+		memory != PasswordHashMemory ||
+		time != PasswordHashTime ||
+		threads != PasswordHashThreads ||
+		uint32(len(hash)) != PasswordHashKeyLen ||
+		len(salt) != PasswordHashSaltLen
+	return true, needRehash, nil
+}
+
+// @title         _MigratePlaintextPasswords
+// @description   把Login表中仍为明文的密码全部哈希,可重复执行
+// @auth          DataEraserC                   (2026/10/17   15:00)
+// @param         GlobalDatabase        *gorm.DB            "全局数据库"
+// @return        count                 int                 "迁移的行数"
+// @return        err                   error               "可能存在的错误"
+func _MigratePlaintextPasswords(GlobalDatabase *gorm.DB) (int, error) {
+	var logins []Login
+	if err := GlobalDatabase.Where("password <> '' AND password NOT LIKE ?", passwordHashPrefix+"%").Find(&logins).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, login := range logins {
+		hashed, err := hashPassword(login.Password)
+		if err != nil {
+			return count, err
+		}
+		// 带上旧密码作为条件,避免覆盖迁移期间被修改过的密码
+		result := GlobalDatabase.Model(&Login{}).
+			Where("user_id = ? AND password = ?", login.UserID, login.Password).
+			Update("password", hashed)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		count += int(result.RowsAffected)
+	}
+	if count > 0 {
+		log.Printf("Migrated %d plaintext passwords\n", count)
+	}
+	return count, nil
+}
+
+// 用户名及密码策略
+var (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+	PasswordMinLength = 8
+	PasswordMaxLength = 64
+)
+
+// @title         validateUsername
+// @description   检查用户名是否符合策略(长度以及只允许字母数字和_.-,且不能是纯数字)
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         username         string              "用户名"
+// @return        err              error               "不符合策略时的原因"
+func validateUsername(username string) error {
+	if len(username) < UsernameMinLength || len(username) > UsernameMaxLength {
+		return fmt.Errorf("用户名长度必须在%d到%d之间", UsernameMinLength, UsernameMaxLength)
+	}
+	allDigits := true
+	for _, r := range username {
+		switch {
+		case r >= '0' && r <= '9':
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == '.', r == '-':
+			allDigits = false
+		default:
+			return errors.New("用户名只能包含字母、数字以及_.-")
+		}
+	}
+	// 纯数字容易与UserID及学号混淆
+	if allDigits {
+		return errors.New("用户名不能是纯数字")
+	}
+	return nil
+}
+
+// @title         validatePassword
+// @description   检查密码是否符合策略(长度以及必须同时包含字母和数字)
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         password         string              "明文密码"
+// @return        err              error               "不符合策略时的原因"
+func validatePassword(password string) error {
+	if len(password) < PasswordMinLength || len(password) > PasswordMaxLength {
+		return fmt.Errorf("密码长度必须在%d到%d之间", PasswordMinLength, PasswordMaxLength)
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			hasLetter = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("密码必须同时包含字母和数字")
+	}
+	return nil
+}
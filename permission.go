@@ -0,0 +1,258 @@
+// @Title       permission.go
+// @Description 放置组织内的角色及权限模型,以及查询用户在组织内权限的工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MemberInfo.Permissions及MemberOf.Permissions的格式为 "角色" 或 "角色:+权限,-权限"
+// 例如 "member:+start_sign" 表示普通成员额外拥有发起签到的权限,
+// "organizer:-edit_attendance" 表示组织者但不能修改考勤.
+// 组织数据库中的MemberInfo是权威数据,用户数据库中的MemberOf只是它的副本,修改时两边同时写入
+
+// 组织内的角色,权限依次增加
+const (
+	// GroupMemberPermissions 普通成员,可以查看组织及签到
+	GroupMemberPermissions = "member"
+	// GroupOrganizerPermissions 组织者,可以管理会议、发起签到及处理考勤
+	GroupOrganizerPermissions = "organizer"
+	// GroupAdminPermissions 组织管理员,在组织者的基础上可以管理成员及组织设置
+	GroupAdminPermissions = "admin"
+	// GroupOwnerPermissions 组织创建者,拥有全部权限,可以任免管理员
+	GroupOwnerPermissions = "owner"
+)
+
+// 组织内的细分权限
+const (
+	// CapViewGroup 查看组织成员及会议
+	CapViewGroup = "view_group"
+	// CapSignIn 参加签到
+	CapSignIn = "sign_in"
+	// CapStartSign 发起、关闭及延长签到
+	CapStartSign = "start_sign"
+	// CapManageMeetings 创建、修改及取消会议
+	CapManageMeetings = "manage_meetings"
+	// CapViewAttendance 查看所有成员的考勤
+	CapViewAttendance = "view_attendance"
+	// CapEditAttendance 修改成员的考勤
+	CapEditAttendance = "edit_attendance"
+	// CapRollCall 随机点名
+	CapRollCall = "roll_call"
+	// CapReviewLeave 审核请假
+	CapReviewLeave = "review_leave"
+	// CapManageMembers 审核加入申请、修改成员权限及移除成员
+	CapManageMembers = "manage_members"
+	// CapManageGroup 修改组织设置
+	CapManageGroup = "manage_group"
+)
+
+// roleRanks 角色的等级,只能管理等级比自己低的成员
+var roleRanks = map[string]int{
+	GroupMemberPermissions:    1,
+	GroupOrganizerPermissions: 2,
+	GroupAdminPermissions:     3,
+	GroupOwnerPermissions:     4,
+}
+
+// roleCapabilities 各角色默认拥有的权限
+var roleCapabilities = map[string][]string{
+	GroupMemberPermissions: {CapViewGroup, CapSignIn},
+	GroupOrganizerPermissions: {CapViewGroup, CapSignIn, CapStartSign, CapManageMeetings,
+		CapViewAttendance, CapEditAttendance, CapRollCall, CapReviewLeave},
+	GroupAdminPermissions: {CapViewGroup, CapSignIn, CapStartSign, CapManageMeetings,
+		CapViewAttendance, CapEditAttendance, CapRollCall, CapReviewLeave, CapManageMembers, CapManageGroup},
+	GroupOwnerPermissions: {CapViewGroup, CapSignIn, CapStartSign, CapManageMeetings,
+		CapViewAttendance, CapEditAttendance, CapRollCall, CapReviewLeave, CapManageMembers, CapManageGroup},
+}
+
+// knownCapabilities 所有合法的细分权限
+var knownCapabilities = map[string]bool{
+	CapViewGroup: true, CapSignIn: true, CapStartSign: true, CapManageMeetings: true,
+	CapViewAttendance: true, CapEditAttendance: true, CapRollCall: true, CapReviewLeave: true,
+	CapManageMembers: true, CapManageGroup: true,
+}
+
+// GroupPermissions 解析后的组织内权限
+type GroupPermissions struct {
+	Role string
+	// Grants 在角色默认权限之外额外授予的权限
+	Grants []string
+	// Revokes 从角色默认权限中去掉的权限
+	Revokes []string
+}
+
+// @title         ParseGroupPermissions
+// @description   解析MemberInfo.Permissions字符串
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Permissions      string              "权限字符串"
+// @return        permissions      GroupPermissions    "解析后的权限"
+// @return        err              error               "格式错误或包含未知的角色、权限"
+func ParseGroupPermissions(Permissions string) (GroupPermissions, error) {
+	role, extra, _ := strings.Cut(strings.TrimSpace(Permissions), ":")
+	permissions := GroupPermissions{Role: strings.TrimSpace(role)}
+	if _, ok := roleRanks[permissions.Role]; !ok {
+		return GroupPermissions{}, fmt.Errorf("未知的角色%q", permissions.Role)
+	}
+	if permissions.Role == GroupOwnerPermissions && strings.TrimSpace(extra) != "" {
+		return GroupPermissions{}, errors.New("组织创建者拥有全部权限,不能增减")
+	}
+	for _, item := range strings.Split(extra, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		capability := item[1:]
+		if !knownCapabilities[capability] {
+			return GroupPermissions{}, fmt.Errorf("未知的权限%q", item)
+		}
+		switch item[0] {
+		case '+':
+			permissions.Grants = append(permissions.Grants, capability)
+		case '-':
+			permissions.Revokes = append(permissions.Revokes, capability)
+		default:
+			return GroupPermissions{}, fmt.Errorf("权限%q必须以+或-开头", item)
+		}
+	}
+	return permissions, nil
+}
+
+// @title         String
+// @description   转换回存入数据库的权限字符串,去掉与角色默认权限重复的增减
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        Permissions      string              "权限字符串"
+func (permissions GroupPermissions) String() string {
+	defaults := make(map[string]bool)
+	for _, capability := range roleCapabilities[permissions.Role] {
+		defaults[capability] = true
+	}
+	var items []string
+	for _, capability := range uniqueSorted(permissions.Grants) {
+		if !defaults[capability] {
+			items = append(items, "+"+capability)
+		}
+	}
+	for _, capability := range uniqueSorted(permissions.Revokes) {
+		if defaults[capability] {
+			items = append(items, "-"+capability)
+		}
+	}
+	if len(items) == 0 {
+		return permissions.Role
+	}
+	return permissions.Role + ":" + strings.Join(items, ",")
+}
+
+// @title         Has
+// @description   判断是否拥有某项权限
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Capability       string              "细分权限"
+// @return        has              bool                "是否拥有"
+func (permissions GroupPermissions) Has(Capability string) bool {
+	for _, capability := range permissions.Revokes {
+		if capability == Capability {
+			return false
+		}
+	}
+	for _, capability := range permissions.Grants {
+		if capability == Capability {
+			return true
+		}
+	}
+	for _, capability := range roleCapabilities[permissions.Role] {
+		if capability == Capability {
+			return true
+		}
+	}
+	return false
+}
+
+// @title         Capabilities
+// @description   列出实际拥有的全部权限
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        capabilities     []string            "按字母排序的权限列表"
+func (permissions GroupPermissions) Capabilities() []string {
+	capabilities := []string{}
+	for capability := range knownCapabilities {
+		if permissions.Has(capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// @title         Rank
+// @description   角色等级,只能管理等级比自己低的成员
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        rank             int                 "角色等级"
+func (permissions GroupPermissions) Rank() int {
+	return roleRanks[permissions.Role]
+}
+
+// uniqueSorted 去重并排序
+func uniqueSorted(items []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// @title         _GetMemberPermissions
+// @description   从组织数据库读取成员的权限
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         UserID                                uint                "用户ID"
+// @return        permissions                           GroupPermissions    "成员的权限"
+// @return        err                                   error               "不是成员时为gorm.ErrRecordNotFound"
+func _GetMemberPermissions(GroupDatabase *gorm.DB, UserID uint) (GroupPermissions, error) {
+	var member MemberInfo
+	if err := GroupDatabase.Where("user_id = ?", UserID).First(&member).Error; err != nil {
+		return GroupPermissions{}, err
+	}
+	return ParseGroupPermissions(member.Permissions)
+}
+
+// @title         _HasGroupCapability
+// @description   判断用户在组织内是否拥有某项权限,不是成员时返回false
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupID                               uint                "组织ID"
+// @param         UserID                                uint                "用户ID"
+// @param         Capability                            string              "细分权限"
+// @return        has                                   bool                "是否拥有"
+// @return        err                                   error               "组织不存在或数据库错误"
+func _HasGroupCapability(GlobalDatabase *gorm.DB, GlobalPath string, GroupID uint, UserID uint, Capability string) (bool, error) {
+	if _, err := _GetGroupInfo(GlobalDatabase, GroupID); err != nil {
+		return false, err
+	}
+	GroupDatabase, err := InitGroup(GlobalPath, GroupID, true)
+	if err != nil {
+		return false, err
+	}
+	defer _CloseDatabase(GroupDatabase)
+
+	permissions, err := _GetMemberPermissions(GroupDatabase, UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return permissions.Has(Capability), nil
+}
@@ -0,0 +1,523 @@
+// @Title       qrcode.go
+// @Description 放置二维码编码器(字节模式、纠错等级M、版本1-10)以及PNG/SVG输出,用于在组织者屏幕上显示签到二维码
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// QRCodeQuietZone 二维码四周空白的模块数
+const QRCodeQuietZone = 4
+
+// qrBlockInfo 纠错等级M下各版本的分块信息
+type qrBlockInfo struct {
+	// ECPerBlock 每块的纠错码字数
+	ECPerBlock int
+	// Blocks1/Data1 第一组的块数及每块数据码字数,Blocks2/Data2 第二组(每块多一个数据码字)
+	Blocks1, Data1, Blocks2, Data2 int
+}
+
+// qrBlockTable 版本1-10纠错等级M的分块表,下标为版本号
+var qrBlockTable = [...]qrBlockInfo{
+	{},
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+}
+
+// qrAlignmentTable 版本1-10的校正图形中心坐标
+var qrAlignmentTable = [...][]int{
+	{}, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// QRCode 编码完成的二维码
+type QRCode struct {
+	Version int
+	Size    int
+	// modules[y][x]为true表示深色模块
+	modules  [][]bool
+	function [][]bool
+}
+
+// @title         EncodeQRCode
+// @description   用字节模式及纠错等级M编码数据,自动选择能容纳数据的最小版本及罚分最低的掩模
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Data             []byte              "需要编码的数据"
+// @return        qr               *QRCode             "二维码"
+// @return        err              error               "数据过长时的错误"
+func EncodeQRCode(Data []byte) (*QRCode, error) {
+	version := 0
+	for v := 1; v < len(qrBlockTable); v++ {
+		if 4+qrCountBits(v)+8*len(Data) <= 8*qrDataCodewords(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("二维码数据过长(%d字节)", len(Data))
+	}
+
+	qr := &QRCode{Version: version, Size: 17 + 4*version}
+	qr.modules = make([][]bool, qr.Size)
+	qr.function = make([][]bool, qr.Size)
+	for y := range qr.modules {
+		qr.modules[y] = make([]bool, qr.Size)
+		qr.function[y] = make([]bool, qr.Size)
+	}
+
+	qr.drawFunctionPatterns()
+	qr.drawCodewords(qrAddErrorCorrection(version, qrDataBits(version, Data)))
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		// 掩模是异或,再做一次即可还原
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+	return qr, nil
+}
+
+// Dark 判断(x, y)处是否为深色模块,超出范围时为浅色
+func (qr *QRCode) Dark(x int, y int) bool {
+	return x >= 0 && y >= 0 && x < qr.Size && y < qr.Size && qr.modules[y][x]
+}
+
+// qrCountBits 字节模式下字符计数的位数
+func qrCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// qrDataCodewords 版本对应的数据码字数
+func qrDataCodewords(version int) int {
+	info := qrBlockTable[version]
+	return info.Blocks1*info.Data1 + info.Blocks2*info.Data2
+}
+
+// qrDataBits 生成模式指示、字符计数、数据、终止符及填充字节组成的数据码字
+func qrDataBits(version int, Data []byte) []byte {
+	var bits []bool
+	appendBits := func(value int, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 == 1)
+		}
+	}
+	appendBits(0x4, 4)
+	appendBits(len(Data), qrCountBits(version))
+	for _, b := range Data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(version) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, len(bits)/8, capacity/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	for pad := byte(0xEC); len(codewords) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// qrAddErrorCorrection 分块计算纠错码并交织
+func qrAddErrorCorrection(version int, Data []byte) []byte {
+	info := qrBlockTable[version]
+	generator := qrGeneratorPolynomial(info.ECPerBlock)
+
+	var dataBlocks, ecBlocks [][]byte
+	offset := 0
+	for i := 0; i < info.Blocks1+info.Blocks2; i++ {
+		length := info.Data1
+		if i >= info.Blocks1 {
+			length = info.Data2
+		}
+		block := Data[offset : offset+length]
+		offset += length
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, qrRemainder(block, generator))
+	}
+
+	result := make([]byte, 0, len(Data)+info.ECPerBlock*len(dataBlocks))
+	for i := 0; i < info.Data1 || i < info.Data2; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ECPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// qrMultiply GF(2^8)(本原多项式0x11D)上的乘法
+func qrMultiply(x byte, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrGeneratorPolynomial 里德-所罗门生成多项式(x-α^0)(x-α^1)...(x-α^(degree-1)),省略最高次项的系数1
+func qrGeneratorPolynomial(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = qrMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrMultiply(root, 0x02)
+	}
+	return result
+}
+
+// qrRemainder 数据多项式除以生成多项式的余数,即纠错码字
+func qrRemainder(Data []byte, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range Data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range generator {
+			result[i] ^= qrMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// setFunction 设置功能图形模块,数据不会写入这些位置
+func (qr *QRCode) setFunction(x int, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// drawFunctionPatterns 绘制定位图形、分隔符、定时图形、校正图形,并为格式及版本信息占位
+func (qr *QRCode) drawFunctionPatterns() {
+	for i := 0; i < qr.Size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	for _, corner := range [][2]int{{3, 3}, {qr.Size - 4, 3}, {3, qr.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || y < 0 || x >= qr.Size || y >= qr.Size {
+					continue
+				}
+				distance := qrChebyshev(dx, dy)
+				qr.setFunction(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+
+	positions := qrAlignmentTable[qr.Version]
+	for i, cy := range positions {
+		for j, cx := range positions {
+			// 与定位图形重叠的三个位置不画
+			if (i == 0 && j == 0) || (i == 0 && j == len(positions)-1) || (i == len(positions)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(cx+dx, cy+dy, qrChebyshev(dx, dy) != 1)
+				}
+			}
+		}
+	}
+
+	// 先用掩模0占位,选好掩模后重新绘制
+	qr.drawFormatBits(0)
+	qr.drawVersionBits()
+}
+
+// drawFormatBits 绘制两份格式信息(纠错等级M及掩模编号)
+func (qr *QRCode) drawFormatBits(mask int) {
+	// 纠错等级M的指示位为00
+	data := mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.Size-15+i, bit(i))
+	}
+	// 固定的深色模块
+	qr.setFunction(8, qr.Size-8, true)
+}
+
+// drawVersionBits 版本7及以上绘制两份版本信息
+func (qr *QRCode) drawVersionBits() {
+	if qr.Version < 7 {
+		return
+	}
+	remainder := qr.Version
+	for i := 0; i < 12; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+	}
+	bits := qr.Version<<12 | remainder
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := qr.Size-11+i%3, i/3
+		qr.setFunction(a, b, dark)
+		qr.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords 按之字形从右下角开始填入数据及纠错码字,剩余位保持浅色
+func (qr *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vertical := 0; vertical < qr.Size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if upward {
+					y = qr.Size - 1 - vertical
+				}
+				if qr.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				qr.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask 对数据模块应用掩模(异或)
+func (qr *QRCode) applyMask(mask int) {
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty 按标准的四条规则计算罚分,用于选择掩模
+func (qr *QRCode) penalty() int {
+	result := 0
+	finderLike := []bool{true, false, true, true, true, false, true}
+	line := make([]bool, qr.Size)
+	for direction := 0; direction < 2; direction++ {
+		for a := 0; a < qr.Size; a++ {
+			for b := 0; b < qr.Size; b++ {
+				if direction == 0 {
+					line[b] = qr.modules[a][b]
+				} else {
+					line[b] = qr.modules[b][a]
+				}
+			}
+			// 规则1: 连续5个及以上同色模块
+			run := 1
+			for b := 1; b <= qr.Size; b++ {
+				if b < qr.Size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			// 规则3: 类似定位图形的1:1:3:1:1且一侧有4个浅色模块
+			for b := 0; b+7 <= qr.Size; b++ {
+				match := true
+				for k, dark := range finderLike {
+					if line[b+k] != dark {
+						match = false
+						break
+					}
+				}
+				if match && (qrLightRun(line, b-4, b) || qrLightRun(line, b+7, b+11)) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			// 规则2: 2x2同色块
+			if x+1 < qr.Size && y+1 < qr.Size {
+				color := qr.modules[y][x]
+				if qr.modules[y][x+1] == color && qr.modules[y+1][x] == color && qr.modules[y+1][x+1] == color {
+					result += 3
+				}
+			}
+		}
+	}
+	// 规则4: 深色模块比例偏离50%
+	total := qr.Size * qr.Size
+	result += qrAbs(dark*100/total-50) / 5 * 10
+	return result
+}
+
+// qrLightRun 判断[from, to)是否全为浅色,超出边界视为浅色(空白区)
+func qrLightRun(line []bool, from int, to int) bool {
+	for i := from; i < to; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// qrAbs 整数绝对值
+func qrAbs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// qrChebyshev 到图形中心的切比雪夫距离,用于绘制同心方框
+func qrChebyshev(dx int, dy int) int {
+	if qrAbs(dx) > qrAbs(dy) {
+		return qrAbs(dx)
+	}
+	return qrAbs(dy)
+}
+
+// @title         PNG
+// @description   输出PNG图片,四周带QRCodeQuietZone个模块的空白
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Scale            int                 "每个模块的像素数"
+// @return        data             []byte              "PNG图片"
+// @return        err              error               "可能存在的错误"
+func (qr *QRCode) PNG(Scale int) ([]byte, error) {
+	if Scale < 1 {
+		return nil, errors.New("Scale必须大于0")
+	}
+	side := (qr.Size + 2*QRCodeQuietZone) * Scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			c := color.Gray{Y: 255}
+			if qr.Dark(px/Scale-QRCodeQuietZone, py/Scale-QRCodeQuietZone) {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(px, py, c)
+		}
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// @title         SVG
+// @description   输出SVG图片,四周带QRCodeQuietZone个模块的空白
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Scale            int                 "每个模块的显示尺寸(像素)"
+// @return        svg              string              "SVG图片"
+func (qr *QRCode) SVG(Scale int) string {
+	if Scale < 1 {
+		Scale = 1
+	}
+	side := qr.Size + 2*QRCodeQuietZone
+	var path strings.Builder
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+QRCodeQuietZone, y+QRCodeQuietZone)
+			}
+		}
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#FFFFFF"/><path d="%s" fill="#000000"/></svg>`+"\n",
+		side, side, side*Scale, side*Scale, path.String())
+}
@@ -0,0 +1,416 @@
+// @Title       rollcall.go
+// @Description 放置随机点名(从参会人或组织成员中随机抽取成员并记录应答情况)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 随机点名的参数
+var (
+	// RollCallMaxCount 一次最多点名的人数
+	RollCallMaxCount = 50
+	// RollCallHistoryMeetings 加权点名时参考最近多少次会议(含本次)的点名及签到情况
+	RollCallHistoryMeetings = 10
+)
+
+// RollCall 点名记录gorm对象,记录被点到的成员及其应答情况
+type RollCall struct {
+	ID     uint
+	UserID uint `gorm:"index"`
+	// Outcome 应答情况 pending/answered/absent
+	Outcome string `gorm:"default:pending"`
+	// Weight 被点到时的权重,不加权时为1
+	Weight    float64
+	CreatedBy uint
+	CreatedAt int64
+	// RecordedBy RecordedAt 登记应答情况的组织者及时间
+	RecordedBy uint
+	RecordedAt int64
+}
+
+// 点名的应答情况
+const (
+	// RollCallPending 尚未登记
+	RollCallPending = "pending"
+	// RollCallAnswered 已应答
+	RollCallAnswered = "answered"
+	// RollCallAbsent 未应答
+	RollCallAbsent = "absent"
+)
+
+// @title         _RollCallWeights
+// @description   根据最近几次会议的点名次数及出勤率计算候选人的权重,最近被点过或出勤率高的成员权重更低
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         meeting                               MeetingInfo         "本次会议"
+// @param         MeetingDatabase                       *gorm.DB            "本次会议的会议数据库"
+// @param         Candidates                            []uint              "候选人"
+// @return        weights                               map[uint]float64    "候选人的权重"
+// @return        err                                   error               "可能存在的错误"
+func _RollCallWeights(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, meeting MeetingInfo, MeetingDatabase *gorm.DB, Candidates []uint) (map[uint]float64, error) {
+	var history []MeetingInfo
+	if err := GroupDatabase.Where("id <> ? AND canceled = ? AND begin_at <= ?", meeting.ID, false, time.Now()).
+		Order("begin_at DESC").Limit(RollCallHistoryMeetings - 1).Find(&history).Error; err != nil {
+		return nil, err
+	}
+
+	picks := make(map[uint]int)
+	signed := make(map[uint]int)
+	var totalSigns int64
+	// tally 累计一个会议数据库中的点名次数及签到次数
+	tally := func(Database *gorm.DB) error {
+		var pickedIDs []uint
+		if err := Database.Model(&RollCall{}).Pluck("user_id", &pickedIDs).Error; err != nil {
+			return err
+		}
+		for _, userID := range pickedIDs {
+			picks[userID]++
+		}
+		var signIDs []uint
+		if err := Database.Model(&Sign{}).Where("begin_at <= ?", time.Now()).Pluck("id", &signIDs).Error; err != nil {
+			return err
+		}
+		totalSigns += int64(len(signIDs))
+		if len(signIDs) == 0 {
+			return nil
+		}
+		var signedIDs []uint
+		if err := Database.Model(&SignatureBook{}).Where("sign_id IN ?", signIDs).Pluck("user_id", &signedIDs).Error; err != nil {
+			return err
+		}
+		for _, userID := range signedIDs {
+			signed[userID]++
+		}
+		return nil
+	}
+
+	if err := tally(MeetingDatabase); err != nil {
+		return nil, err
+	}
+	for _, past := range history {
+		PastDatabase, err := InitMeeting(GlobalPath, GroupID, past.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		err = tally(PastDatabase)
+		_CloseDatabase(PastDatabase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	weights := make(map[uint]float64)
+	for _, userID := range Candidates {
+		rate := 1.0
+		if totalSigns > 0 {
+			rate = float64(signed[userID]) / float64(totalSigns)
+		}
+		// 出勤率从100%降到0%时权重翻倍,每被点过一次权重降为1/(1+次数)
+		weights[userID] = (2 - rate) / float64(1+picks[userID])
+	}
+	return weights, nil
+}
+
+// @title         weightedSample
+// @description   按权重不放回地随机抽取(Efraimidis-Spirakis算法),权重为空时等概率抽取
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Candidates       []uint              "候选人"
+// @param         Weights          map[uint]float64    "候选人的权重,可以为nil"
+// @param         Count            int                 "抽取人数,超过候选人数时全部抽出"
+// @return        picked           []uint              "按抽中顺序排列的候选人"
+func weightedSample(Candidates []uint, Weights map[uint]float64, Count int) []uint {
+	type keyed struct {
+		userID uint
+		key    float64
+	}
+	keys := make([]keyed, 0, len(Candidates))
+	for _, userID := range Candidates {
+		weight := 1.0
+		if Weights != nil {
+			weight = Weights[userID]
+		}
+		if weight <= 0 {
+			continue
+		}
+		// key = u^(1/w),取key最大的Count个;取对数避免权重很小时下溢为0
+		keys = append(keys, keyed{userID: userID, key: math.Log(1-rand.Float64()) / weight})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key > keys[j].key })
+	if Count > len(keys) {
+		Count = len(keys)
+	}
+	picked := make([]uint, 0, Count)
+	for _, item := range keys[:Count] {
+		picked = append(picked, item.userID)
+	}
+	return picked
+}
+
+// @title         _RollCallData
+// @description   把点名记录连同成员的姓名昵称转换为返回给前端的数据
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         RollCalls                             []RollCall          "点名记录"
+// @return        data                                  []gin.H             "返回数据"
+// @return        err                                   error               "可能存在的错误"
+func _RollCallData(GlobalDatabase *gorm.DB, RollCalls []RollCall) ([]gin.H, error) {
+	userIDs := make([]uint, 0, len(RollCalls))
+	for _, rollCall := range RollCalls {
+		userIDs = append(userIDs, rollCall.UserID)
+	}
+	var users []UserInfo
+	if len(userIDs) > 0 {
+		if err := GlobalDatabase.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	userMap := make(map[uint]UserInfo)
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+
+	data := make([]gin.H, 0, len(RollCalls))
+	for _, rollCall := range RollCalls {
+		user := userMap[rollCall.UserID]
+		data = append(data, gin.H{
+			"ID":         rollCall.ID,
+			"UserID":     rollCall.UserID,
+			"Name":       user.Name,
+			"NickName":   user.NickName,
+			"Avatar":     user.Avatar,
+			"Outcome":    rollCall.Outcome,
+			"Weight":     rollCall.Weight,
+			"CreatedBy":  rollCall.CreatedBy,
+			"CreatedAt":  rollCall.CreatedAt,
+			"RecordedBy": rollCall.RecordedBy,
+			"RecordedAt": rollCall.RecordedAt,
+		})
+	}
+	return data, nil
+}
+
+// @title         Roll_call
+// @description   在会议中随机点名,可以按最近被点次数及出勤率加权,默认不重复点到本次会议已经点过的成员,需要先经过GroupMiddleware(CapRollCall)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Roll_call(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			// Count 点名人数,为0时点1人
+			Count int
+			// Weighted 最近被点过或出勤率高的成员更不容易被点到
+			Weighted bool
+			// AllowRepeat 允许点到本次会议已经点过的成员
+			AllowRepeat bool
+			// ExcludeUserIDs 这次不参与点名的成员
+			ExcludeUserIDs []uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.Count < 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if request.Count == 0 {
+			request.Count = 1
+		}
+		if request.Count > RollCallMaxCount {
+			c.JSON(400, gin.H{"code": 1, "message": "点名人数过多"})
+			return
+		}
+
+		meeting, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+		if meeting.Canceled {
+			c.JSON(400, gin.H{"code": 6, "message": "会议已取消"})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		candidates, err := _ExpectedAttendees(GroupDatabase, MeetingDatabase, meeting)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		// 请假已通过的成员不参与点名
+		excluded, err := _ExcusedUserIDs(GroupDatabase, meeting)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		// 会议期间退出组织的成员不参与点名
+		var memberIDs []uint
+		if err := GroupDatabase.Model(&MemberInfo{}).Pluck("user_id", &memberIDs).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		members := make(map[uint]bool)
+		for _, userID := range memberIDs {
+			members[userID] = true
+		}
+		for _, userID := range candidates {
+			if !members[userID] {
+				excluded[userID] = true
+			}
+		}
+		for _, userID := range request.ExcludeUserIDs {
+			excluded[userID] = true
+		}
+		if !request.AllowRepeat {
+			var pickedIDs []uint
+			if err := MeetingDatabase.Model(&RollCall{}).Pluck("user_id", &pickedIDs).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+			for _, userID := range pickedIDs {
+				excluded[userID] = true
+			}
+		}
+		available := make([]uint, 0, len(candidates))
+		for _, userID := range candidates {
+			if !excluded[userID] {
+				available = append(available, userID)
+			}
+		}
+		if len(available) == 0 {
+			c.JSON(400, gin.H{"code": 18, "message": "没有可以点名的成员"})
+			return
+		}
+
+		var weights map[uint]float64
+		if request.Weighted {
+			weights, err = _RollCallWeights(GlobalPath, GroupDatabase, _GetContextGroupID(c), meeting, MeetingDatabase, available)
+			if err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+		}
+
+		now := time.Now().Unix()
+		rollCalls := []RollCall{}
+		for _, userID := range weightedSample(available, weights, request.Count) {
+			weight := 1.0
+			if weights != nil {
+				weight = weights[userID]
+			}
+			rollCalls = append(rollCalls, RollCall{
+				UserID:    userID,
+				Outcome:   RollCallPending,
+				Weight:    weight,
+				CreatedBy: _GetContextUserID(c),
+				CreatedAt: now,
+			})
+		}
+		if err := MeetingDatabase.Create(&rollCalls).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		data, err := _RollCallData(GlobalDatabase, rollCalls)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "点名成功", "data": data, "Candidates": len(available)})
+	}
+}
+
+// @title         Record_roll_call
+// @description   登记被点到的成员是否应答,可以重复登记以更正,需要先经过GroupMiddleware(CapRollCall)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Record_roll_call(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID  uint
+			RollCallID uint
+			// Outcome answered/absent
+			Outcome string
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.RollCallID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if request.Outcome != RollCallAnswered && request.Outcome != RollCallAbsent {
+			c.JSON(400, gin.H{"code": 1, "message": "Outcome只能是answered或absent"})
+			return
+		}
+
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var rollCall RollCall
+		if err := MeetingDatabase.First(&rollCall, request.RollCallID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 19, "message": "点名记录不存在"})
+			return
+		}
+		rollCall.Outcome = request.Outcome
+		rollCall.RecordedBy = _GetContextUserID(c)
+		rollCall.RecordedAt = time.Now().Unix()
+		if err := MeetingDatabase.Model(&RollCall{}).Where("id = ?", rollCall.ID).Updates(map[string]interface{}{
+			"outcome":     rollCall.Outcome,
+			"recorded_by": rollCall.RecordedBy,
+			"recorded_at": rollCall.RecordedAt,
+		}).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "登记成功", "data": rollCall})
+	}
+}
+
+// @title         Roll_calls
+// @description   列出会议中的点名记录,需要先经过GroupMiddleware(CapRollCall)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Roll_calls(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		var rollCalls []RollCall
+		if err := MeetingDatabase.Order("id ASC").Find(&rollCalls).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		data, err := _RollCallData(GlobalDatabase, rollCalls)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取点名记录成功", "data": data})
+	}
+}
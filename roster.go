@@ -0,0 +1,297 @@
+// @Title       roster.go
+// @Description 放置从教务导出的csv或xlsx名单按学号批量导入组织成员的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 名单文件的参数
+var (
+	// RosterMaxSize 名单文件最大字节数
+	RosterMaxSize = 5 << 20
+	// RosterMaxRows 名单最多多少行(含表头)
+	RosterMaxRows = 5000
+)
+
+// 导入名单时每一行的处理结果
+const (
+	// RosterActionAdd 学号对应已有的占位用户或验证过学号的用户,加入组织
+	RosterActionAdd = "add"
+	// RosterActionCreate 没有学号对应的用户,创建待认领的占位用户并加入组织
+	RosterActionCreate = "create"
+	// RosterActionExists 已经是组织成员,不做修改(占位用户会更新个人信息)
+	RosterActionExists = "exists"
+	// RosterActionError 这一行有错误,跳过
+	RosterActionError = "error"
+)
+
+// rosterColumns 名单表头(去掉空白并转为小写后)对应的UserInfo字段
+var rosterColumns = map[string]string{
+	"学号": "RegistrationNumber", "registrationnumber": "RegistrationNumber",
+	"姓名": "Name", "name": "Name",
+	"学院": "Collage", "collage": "Collage", "college": "Collage",
+	"专业": "Majar", "majar": "Majar", "major": "Majar",
+	"年级": "Grade", "grade": "Grade",
+	"性别": "Gender", "gender": "Gender",
+	"手机号": "PhoneNumber", "手机": "PhoneNumber", "phonenumber": "PhoneNumber", "phone": "PhoneNumber",
+}
+
+// RosterImportResult 名单中一行的导入结果
+type RosterImportResult struct {
+	// Row 在文件中的行号,从1开始
+	Row                int
+	RegistrationNumber string
+	Name               string
+	// UserID 对应的用户,预览时新建的占位用户为0
+	UserID uint
+	// Action add/create/exists/error
+	Action string
+	Error  string
+	// Confirm 学号匹配到已注册的用户(不是占位用户),需要组织者核对MatchedName与名单中的姓名
+	Confirm     bool
+	MatchedName string
+}
+
+// rosterFile 读取出来的名单
+type rosterFile struct {
+	Rows [][]string
+	// HeaderRow 表头所在的行(从0开始),之后的行为数据
+	HeaderRow int
+	// Columns 每个UserInfo字段所在的列
+	Columns map[string]int
+}
+
+// @title         _ReadRoster
+// @description   按扩展名读取csv或xlsx名单并找出表头,csv必须是UTF-8编码(可以带BOM)
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         FileName                              string              "文件名"
+// @param         Content                               []byte              "文件内容"
+// @return        roster                                rosterFile          "名单"
+// @return        err                                   error               "文件格式错误或表头中没有学号列"
+func _ReadRoster(FileName string, Content []byte) (rosterFile, error) {
+	var roster rosterFile
+	var err error
+	switch strings.ToLower(filepath.Ext(FileName)) {
+	case ".xlsx":
+		roster.Rows, err = ReadXLSXRows(bytes.NewReader(Content), int64(len(Content)), RosterMaxRows)
+	case ".csv":
+		Content = bytes.TrimPrefix(Content, []byte("\ufeff"))
+		if !utf8.Valid(Content) {
+			return roster, errors.New("csv文件必须是UTF-8编码,请另存为xlsx或UTF-8编码的csv")
+		}
+		reader := csv.NewReader(bytes.NewReader(Content))
+		reader.FieldsPerRecord = -1
+		roster.Rows, err = reader.ReadAll()
+		if err == nil && len(roster.Rows) > RosterMaxRows {
+			err = fmt.Errorf("最多只能有%d行", RosterMaxRows)
+		}
+	default:
+		err = errors.New("名单只能是csv或xlsx文件")
+	}
+	if err != nil {
+		return roster, err
+	}
+
+	for roster.HeaderRow < len(roster.Rows) && strings.TrimSpace(strings.Join(roster.Rows[roster.HeaderRow], "")) == "" {
+		roster.HeaderRow++
+	}
+	if roster.HeaderRow == len(roster.Rows) {
+		return roster, errors.New("名单是空的")
+	}
+	roster.Columns = make(map[string]int)
+	for i, cell := range roster.Rows[roster.HeaderRow] {
+		key := strings.ToLower(strings.Join(strings.Fields(cell), ""))
+		if field, ok := rosterColumns[key]; ok {
+			if _, exists := roster.Columns[field]; !exists {
+				roster.Columns[field] = i
+			}
+		}
+	}
+	if _, ok := roster.Columns["RegistrationNumber"]; !ok {
+		return roster, errors.New("名单的表头中没有学号列")
+	}
+	return roster, nil
+}
+
+// @title         _ImportRoster
+// @description   按学号把名单中的学生加入组织,学号对应占位用户或验证过学号的用户时直接加入,否则创建待认领的占位用户,已是成员的跳过,重复导入结果不变
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         Roster                                rosterFile          "名单"
+// @param         DryRun                                bool                "只预览,不写入"
+// @return        results                               []RosterImportResult "每一行的处理结果"
+// @return        err                                   error               "数据库错误,每一行的错误记录在结果中"
+func _ImportRoster(GlobalDatabase *gorm.DB, GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, Roster rosterFile, DryRun bool) ([]RosterImportResult, error) {
+	results := []RosterImportResult{}
+	seen := make(map[string]int)
+	for i := Roster.HeaderRow + 1; i < len(Roster.Rows); i++ {
+		row := Roster.Rows[i]
+		cell := func(field string) string {
+			if column, ok := Roster.Columns[field]; ok && column < len(row) {
+				return strings.TrimSpace(row[column])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		entry := UserInfo{
+			Name:               cell("Name"),
+			Gender:             cell("Gender"),
+			Collage:            cell("Collage"),
+			Majar:              cell("Majar"),
+			PhoneNumber:        cell("PhoneNumber"),
+			RegistrationNumber: cell("RegistrationNumber"),
+		}
+		result := RosterImportResult{Row: i + 1, RegistrationNumber: entry.RegistrationNumber, Name: entry.Name, Action: RosterActionError}
+		fail := func(message string) {
+			result.Error = message
+			results = append(results, result)
+		}
+		if entry.RegistrationNumber == "" {
+			fail("缺少学号")
+			continue
+		}
+		if previous, ok := seen[entry.RegistrationNumber]; ok {
+			fail(fmt.Sprintf("学号与第%d行重复", previous))
+			continue
+		}
+		seen[entry.RegistrationNumber] = result.Row
+		if grade := cell("Grade"); grade != "" {
+			value, err := strconv.ParseUint(grade, 10, 32)
+			if err != nil {
+				fail("年级必须是数字")
+				continue
+			}
+			entry.Grade = uint(value)
+		}
+
+		// 用户可以自己填写学号,只有占位用户和认领时验证过的学号可信,其他同学号的用户等认领占位用户后再合并
+		var users []UserInfo
+		if err := GlobalDatabase.Where("registration_number = ? AND (placeholder = ? OR registration_verified = ?)", entry.RegistrationNumber, true, true).
+			Limit(2).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		if len(users) > 1 {
+			fail("学号对应多个用户,请先用merge-user子命令合并")
+			continue
+		}
+		if len(users) == 1 {
+			result.UserID = users[0].ID
+			if !users[0].Placeholder {
+				result.Confirm = true
+				result.MatchedName = users[0].Name
+			}
+			var count int64
+			if err := GroupDatabase.Model(&MemberInfo{}).Where("user_id = ?", result.UserID).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			result.Action = RosterActionAdd
+			if count > 0 {
+				result.Action = RosterActionExists
+			}
+		} else {
+			result.Action = RosterActionCreate
+		}
+		if DryRun {
+			results = append(results, result)
+			continue
+		}
+
+		switch {
+		case result.Action == RosterActionCreate:
+			entry.Placeholder = true
+			if err := GlobalDatabase.Create(&entry).Error; err != nil {
+				return nil, err
+			}
+			result.UserID = entry.ID
+		case users[0].Placeholder:
+			// 占位用户的个人信息以最新的名单为准,学生认领后不再修改
+			if err := GlobalDatabase.Model(&UserInfo{}).Where("id = ? AND placeholder = ?", result.UserID, true).Updates(entry).Error; err != nil {
+				return nil, err
+			}
+		}
+		if result.Action != RosterActionExists {
+			if err := _AddGroupMemberTo(GroupDatabase, GlobalPath, GroupID, result.UserID, GroupMemberPermissions); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// @title         Import_roster
+// @description   按学号从csv或xlsx名单批量导入组织成员,DryRun为true时只返回每一行的处理结果而不写入,需要先经过GroupMiddleware(CapManageMembers)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Import_roster(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// FileName 名单的文件名,根据扩展名判断是csv还是xlsx
+			FileName string
+			// File base64编码的文件内容
+			File   string
+			DryRun bool
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.File == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		content, err := base64.StdEncoding.DecodeString(request.File)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "文件不是合法的base64"})
+			return
+		}
+		if len(content) > RosterMaxSize {
+			c.JSON(400, gin.H{"code": 1, "message": fmt.Sprintf("名单不能超过%dKB", RosterMaxSize>>10)})
+			return
+		}
+		roster, err := _ReadRoster(request.FileName, content)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 30, "message": "无法读取名单: " + err.Error()})
+			return
+		}
+
+		results, err := _ImportRoster(GlobalDatabase, GlobalPath, _GetContextGroupDatabase(c), _GetContextGroupID(c), roster, request.DryRun)
+		if err != nil {
+			// 已经处理的行保持不变,重新导入即可
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		summary := map[string]int{RosterActionAdd: 0, RosterActionCreate: 0, RosterActionExists: 0, RosterActionError: 0}
+		confirm := 0
+		for _, result := range results {
+			summary[result.Action]++
+			if result.Confirm {
+				confirm++
+			}
+		}
+		message := "导入名单成功"
+		if request.DryRun {
+			message = "预览名单成功"
+		}
+		c.JSON(200, gin.H{"code": 0, "message": message, "DryRun": request.DryRun, "Summary": summary, "Confirm": confirm, "data": results})
+	}
+}
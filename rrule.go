@@ -0,0 +1,248 @@
+// @Title       rrule.go
+// @Description 放置RFC 5545 RRULE(只支持按天、按周、按月重复)的解析及展开,用于生成重复会议
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重复的频率
+const (
+	RRuleDaily   = "DAILY"
+	RRuleWeekly  = "WEEKLY"
+	RRuleMonthly = "MONTHLY"
+)
+
+// rruleWeekdays RRULE中星期的写法
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// rruleMaxPeriods 展开时最多遍历多少个周期,防止筛选条件永远不满足时死循环
+const rruleMaxPeriods = 10000
+
+// RecurrenceRule 解析后的RRULE
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	// Count Until 二者必须有一个,Until包含当天
+	Count int
+	Until time.Time
+	// ByDay 按周重复时为每周的哪几天(默认为第一次的星期),按天重复时为筛选,按月重复时为每月所有这几个星期
+	ByDay []time.Weekday
+	// ByMonthDay 按月重复时为每月的哪几天,负数表示倒数
+	ByMonthDay []int
+	// WeekStart 每周从星期几开始,影响INTERVAL大于1时怎样分周
+	WeekStart time.Weekday
+}
+
+// @title         ParseRRule
+// @description   解析RRULE字符串(可以带RRULE:前缀),必须指定COUNT或UNTIL,不支持的部分返回错误
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Text             string              "RRULE字符串,比如FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=16"
+// @return        rule             RecurrenceRule      "解析后的规则"
+// @return        err              error               "格式错误或使用了不支持的部分"
+func ParseRRule(Text string) (RecurrenceRule, error) {
+	rule := RecurrenceRule{Interval: 1, WeekStart: time.Monday}
+	Text = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(Text)), "RRULE:")
+	if Text == "" {
+		return rule, errors.New("RRULE不能为空")
+	}
+	for _, part := range strings.Split(Text, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("RRULE中的%s格式错误", part)
+		}
+		var err error
+		switch key {
+		case "FREQ":
+			if value != RRuleDaily && value != RRuleWeekly && value != RRuleMonthly {
+				return rule, errors.New("FREQ只能是DAILY、WEEKLY或MONTHLY")
+			}
+			rule.Freq = value
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 {
+				return rule, errors.New("INTERVAL必须是正整数")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return rule, errors.New("COUNT必须是正整数")
+			}
+		case "UNTIL":
+			rule.Until, err = parseRRuleTime(value)
+			if err != nil {
+				return rule, err
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[day]
+				if !ok {
+					return rule, fmt.Errorf("BYDAY中的%s不是星期(不支持1MO这样带序号的写法)", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return rule, fmt.Errorf("BYMONTHDAY中的%s不是合法的日期", day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "WKST":
+			weekday, ok := rruleWeekdays[value]
+			if !ok {
+				return rule, errors.New("WKST必须是星期")
+			}
+			rule.WeekStart = weekday
+		default:
+			return rule, fmt.Errorf("不支持RRULE中的%s", key)
+		}
+	}
+	if rule.Freq == "" {
+		return rule, errors.New("RRULE必须指定FREQ")
+	}
+	if rule.Count == 0 && rule.Until.IsZero() {
+		return rule, errors.New("RRULE必须指定COUNT或UNTIL")
+	}
+	if rule.Count != 0 && !rule.Until.IsZero() {
+		return rule, errors.New("COUNT和UNTIL不能同时指定")
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != RRuleMonthly {
+		return rule, errors.New("只有按月重复时可以指定BYMONTHDAY")
+	}
+	return rule, nil
+}
+
+// @title         parseRRuleTime
+// @description   解析UNTIL,只有日期时包含当天,带Z时为UTC,否则为服务器时区
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Value            string              "20060102、20060102T150405或20060102T150405Z"
+// @return        until            time.Time           "截止时间"
+// @return        err              error               "格式错误"
+func parseRRuleTime(Value string) (time.Time, error) {
+	if until, err := time.ParseInLocation("20060102", Value, time.Local); err == nil {
+		return until.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	if until, err := time.Parse("20060102T150405Z", Value); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102T150405", Value, time.Local); err == nil {
+		return until, nil
+	}
+	return time.Time{}, errors.New("UNTIL的格式应为20060102或20060102T150405Z")
+}
+
+// @title         hasWeekday
+// @description   判断星期是否在列表中
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Days             []time.Weekday      "星期列表"
+// @param         Day              time.Weekday        "星期"
+// @return        ok               bool                "是否在列表中"
+func hasWeekday(Days []time.Weekday, Day time.Weekday) bool {
+	for _, day := range Days {
+		if day == Day {
+			return true
+		}
+	}
+	return false
+}
+
+// @title         periodDays
+// @description   列出一个周期内符合规则的日期(只有年月日有意义),按日期升序
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Period           int                 "从第一次所在周期开始的第几个周期"
+// @param         Start            time.Time           "第一次的开始时间"
+// @return        days             []time.Time         "日期"
+func (rule RecurrenceRule) periodDays(Period int, Start time.Time) []time.Time {
+	date := time.Date(Start.Year(), Start.Month(), Start.Day(), 0, 0, 0, 0, time.Local)
+	days := []time.Time{}
+	switch rule.Freq {
+	case RRuleDaily:
+		day := date.AddDate(0, 0, Period*rule.Interval)
+		if len(rule.ByDay) == 0 || hasWeekday(rule.ByDay, day.Weekday()) {
+			days = append(days, day)
+		}
+	case RRuleWeekly:
+		weekStart := date.AddDate(0, 0, -((int(date.Weekday())-int(rule.WeekStart))+7)%7+Period*rule.Interval*7)
+		byDay := rule.ByDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{Start.Weekday()}
+		}
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if hasWeekday(byDay, day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case RRuleMonthly:
+		month := time.Date(date.Year(), date.Month()+time.Month(Period*rule.Interval), 1, 0, 0, 0, 0, time.Local)
+		length := month.AddDate(0, 1, -1).Day()
+		for day := 1; day <= length; day++ {
+			current := month.AddDate(0, 0, day-1)
+			match := false
+			switch {
+			case len(rule.ByMonthDay) > 0:
+				for _, monthDay := range rule.ByMonthDay {
+					if monthDay == day || monthDay < 0 && length+monthDay+1 == day {
+						match = true
+					}
+				}
+			case len(rule.ByDay) > 0:
+				match = hasWeekday(rule.ByDay, current.Weekday())
+			default:
+				match = day == Start.Day()
+			}
+			if match {
+				days = append(days, current)
+			}
+		}
+	}
+	return days
+}
+
+// @title         Expand
+// @description   从第一次的开始时间展开所有重复的开始时间,每次都与第一次的钟点相同,不早于第一次,COUNT在去掉例外日期之前计算
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Start            time.Time           "第一次的开始时间"
+// @param         ExDates          map[string]bool     "跳过的日期(2006-01-02)"
+// @param         Max              int                 "最多多少次,超过时返回错误"
+// @return        begins           []time.Time         "按时间升序的开始时间"
+// @return        err              error               "次数超过Max"
+func (rule RecurrenceRule) Expand(Start time.Time, ExDates map[string]bool, Max int) ([]time.Time, error) {
+	Start = Start.Local()
+	begins := []time.Time{}
+	count := 0
+	for period := 0; period < rruleMaxPeriods; period++ {
+		for _, day := range rule.periodDays(period, Start) {
+			begin := time.Date(day.Year(), day.Month(), day.Day(), Start.Hour(), Start.Minute(), Start.Second(), 0, time.Local)
+			if begin.Before(Start) {
+				continue
+			}
+			if !rule.Until.IsZero() && begin.After(rule.Until) {
+				return begins, nil
+			}
+			count++
+			if !ExDates[begin.Format(time.DateOnly)] {
+				if len(begins) == Max {
+					return nil, fmt.Errorf("最多只能重复%d次", Max)
+				}
+				begins = append(begins, begin)
+			}
+			if rule.Count != 0 && count == rule.Count {
+				return begins, nil
+			}
+		}
+	}
+	return begins, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// useLocation 测试期间把time.Local换成固定时区,结束时恢复
+func useLocation(t *testing.T, location *time.Location) {
+	previous := time.Local
+	time.Local = location
+	t.Cleanup(func() { time.Local = previous })
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"lowercase with prefix", "RRULE:freq=weekly;byday=tu,th;count=32", false},
+		{"until", "FREQ=DAILY;UNTIL=20261231", false},
+		{"empty", "", true},
+		{"yearly", "FREQ=YEARLY;COUNT=1", true},
+		{"missing freq", "COUNT=3", true},
+		{"missing count and until", "FREQ=DAILY", true},
+		{"count and until", "FREQ=DAILY;COUNT=1;UNTIL=20261231", true},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0;COUNT=1", true},
+		{"ordinal byday", "FREQ=MONTHLY;BYDAY=1MO;COUNT=1", true},
+		{"bymonthday out of range", "FREQ=MONTHLY;BYMONTHDAY=32;COUNT=1", true},
+		{"bymonthday zero", "FREQ=MONTHLY;BYMONTHDAY=0;COUNT=1", true},
+		{"bymonthday with weekly", "FREQ=WEEKLY;BYMONTHDAY=1;COUNT=1", true},
+		{"unsupported part", "FREQ=DAILY;COUNT=1;BYSETPOS=1", true},
+		{"bad until", "FREQ=DAILY;UNTIL=2026-12-31", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseRRule(test.text)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseRRule(%q) err = %v, wantErr %v", test.text, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecurrenceRuleExpand(t *testing.T) {
+	useLocation(t, time.FixedZone("CST", 8*3600))
+	at := func(value string) time.Time {
+		begin, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return begin
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		start   string
+		exDates []string
+		// want 为空时只检查count、first及last
+		want    []string
+		count   int
+		first   string
+		last    string
+		wantErr bool
+	}{
+		{
+			name:  "tue and thu for 16 weeks",
+			rule:  "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=32",
+			start: "2026-09-01 08:00",
+			count: 32, first: "2026-09-01 08:00", last: "2026-12-17 08:00",
+		},
+		{
+			name:    "holiday excluded after count",
+			rule:    "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=32",
+			start:   "2026-09-01 08:00",
+			exDates: []string{"2026-10-01"},
+			count:   31, first: "2026-09-01 08:00", last: "2026-12-17 08:00",
+		},
+		{
+			name:  "biweekly",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;UNTIL=20261031",
+			start: "2026-09-01 08:00",
+			want:  []string{"2026-09-01 08:00", "2026-09-15 08:00", "2026-09-29 08:00", "2026-10-13 08:00", "2026-10-27 08:00"},
+		},
+		{
+			// RFC 5545 3.8.5.3中WKST影响分周的例子
+			name:  "interval 2 with monday week start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			start: "1997-08-05 09:00",
+			want:  []string{"1997-08-05 09:00", "1997-08-10 09:00", "1997-08-19 09:00", "1997-08-24 09:00"},
+		},
+		{
+			name:  "interval 2 with sunday week start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			start: "1997-08-05 09:00",
+			want:  []string{"1997-08-05 09:00", "1997-08-17 09:00", "1997-08-19 09:00", "1997-08-31 09:00"},
+		},
+		{
+			name:  "start not on byday",
+			rule:  "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=2",
+			start: "2026-08-31 08:00",
+			want:  []string{"2026-09-01 08:00", "2026-09-03 08:00"},
+		},
+		{
+			name:  "daily filtered by byday",
+			rule:  "FREQ=DAILY;BYDAY=MO,WE,FR;COUNT=4",
+			start: "2026-09-01 08:00",
+			want:  []string{"2026-09-02 08:00", "2026-09-04 08:00", "2026-09-07 08:00", "2026-09-09 08:00"},
+		},
+		{
+			name:  "bymonthday 31 and -1",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31,-1;COUNT=4",
+			start: "2026-01-31 10:00",
+			want:  []string{"2026-01-31 10:00", "2026-02-28 10:00", "2026-03-31 10:00", "2026-04-30 10:00"},
+		},
+		{
+			name:  "bymonthday -1 in leap year",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2",
+			start: "2028-01-31 10:00",
+			want:  []string{"2028-01-31 10:00", "2028-02-29 10:00"},
+		},
+		{
+			name:  "bymonthday 31 skips short months",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			start: "2026-01-31 10:00",
+			want:  []string{"2026-01-31 10:00", "2026-03-31 10:00", "2026-05-31 10:00"},
+		},
+		{
+			name:  "monthly on start day",
+			rule:  "FREQ=MONTHLY;INTERVAL=2;COUNT=3",
+			start: "2026-01-15 10:00",
+			want:  []string{"2026-01-15 10:00", "2026-03-15 10:00", "2026-05-15 10:00"},
+		},
+		{
+			name:    "exdate counted by count",
+			rule:    "FREQ=DAILY;COUNT=5",
+			start:   "2026-09-01 08:00",
+			exDates: []string{"2026-09-02", "2026-09-04"},
+			want:    []string{"2026-09-01 08:00", "2026-09-03 08:00", "2026-09-05 08:00"},
+		},
+		{
+			name:    "exdate outside the rule",
+			rule:    "FREQ=DAILY;COUNT=2",
+			start:   "2026-09-01 08:00",
+			exDates: []string{"2026-08-31", "2026-09-03"},
+			want:    []string{"2026-09-01 08:00", "2026-09-02 08:00"},
+		},
+		{
+			name:  "until date includes the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20260903",
+			start: "2026-09-01 23:00",
+			want:  []string{"2026-09-01 23:00", "2026-09-02 23:00", "2026-09-03 23:00"},
+		},
+		{
+			// 2026-09-03 08:00 CST = 2026-09-03T00:00:00Z,晚于UNTIL
+			name:  "until in utc",
+			rule:  "FREQ=DAILY;UNTIL=20260902T235959Z",
+			start: "2026-09-01 08:00",
+			want:  []string{"2026-09-01 08:00", "2026-09-02 08:00"},
+		},
+		{
+			name:  "until in utc equal to occurrence",
+			rule:  "FREQ=DAILY;UNTIL=20260903T000000Z",
+			start: "2026-09-01 08:00",
+			want:  []string{"2026-09-01 08:00", "2026-09-02 08:00", "2026-09-03 08:00"},
+		},
+		{
+			name:  "until in local time",
+			rule:  "FREQ=DAILY;UNTIL=20260902T080000",
+			start: "2026-09-01 08:00",
+			want:  []string{"2026-09-01 08:00", "2026-09-02 08:00"},
+		},
+		{
+			name:  "until before start",
+			rule:  "FREQ=DAILY;UNTIL=20260801",
+			start: "2026-09-01 08:00",
+			want:  []string{},
+		},
+		{
+			name:    "too many occurrences",
+			rule:    "FREQ=DAILY;COUNT=201",
+			start:   "2026-09-01 08:00",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exDates := make(map[string]bool)
+			for _, date := range test.exDates {
+				exDates[date] = true
+			}
+			begins, err := rule.Expand(at(test.start), exDates, 200)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expand err = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			got := make([]string, 0, len(begins))
+			for _, begin := range begins {
+				if begin.Location() != time.Local {
+					t.Errorf("%v is not in local time", begin)
+				}
+				got = append(got, begin.Format("2006-01-02 15:04"))
+			}
+			if test.want != nil {
+				if len(got) != len(test.want) {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+				for i := range got {
+					if got[i] != test.want[i] {
+						t.Fatalf("got %v, want %v", got, test.want)
+					}
+				}
+				return
+			}
+			if len(got) != test.count || got[0] != test.first || got[len(got)-1] != test.last {
+				t.Fatalf("got %d occurrences from %s to %s, want %d from %s to %s",
+					len(got), got[0], got[len(got)-1], test.count, test.first, test.last)
+			}
+		})
+	}
+}
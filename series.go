@@ -0,0 +1,449 @@
+// @Title       series.go
+// @Description 放置重复会议(按RRULE生成每一次会议及其会议数据库,可以修改单次或者整个重复会议)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// MeetingSeriesMaxOccurrences 一个重复会议最多生成多少次会议
+var MeetingSeriesMaxOccurrences = 200
+
+// MeetingSeries 重复会议gorm对象,每一次会议都是一个MeetingInfo,以SeriesID关联
+type MeetingSeries struct {
+	ID uint
+	// BeginAt EndAt 第一次会议的时间,之后每次的钟点及时长与第一次相同
+	BeginAt time.Time
+	EndAt   time.Time
+	// RRule RFC 5545 RRULE,比如FREQ=WEEKLY;BYDAY=TU,TH;COUNT=32
+	RRule string
+	// ExDates 跳过的日期(节假日等),逗号分隔的2006-01-02
+	ExDates            string
+	MeetingDescription string
+	Geofence           Geofence `gorm:"embedded;embeddedPrefix:fence_"`
+	// Canceled 取消后不再生成或修改会议,已经结束的会议保留
+	Canceled  bool
+	CreatedBy uint
+	CreatedAt int64
+}
+
+// @title         _ParseExDates
+// @description   检查并规范化跳过的日期
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Dates            []string            "日期(2006-01-02)"
+// @return        text             string              "按日期升序、逗号分隔的日期"
+// @return        err              error               "日期格式错误"
+func _ParseExDates(Dates []string) (string, error) {
+	dates := make([]string, 0, len(Dates))
+	seen := make(map[string]bool)
+	for _, date := range Dates {
+		date = strings.TrimSpace(date)
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return "", fmt.Errorf("日期%s的格式应为2006-01-02", date)
+		}
+		if !seen[date] {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return strings.Join(dates, ","), nil
+}
+
+// @title         _SeriesOccurrences
+// @description   按重复会议的规则生成每一次会议(尚未写入数据库)
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         series           MeetingSeries       "重复会议"
+// @return        meetings         []MeetingInfo       "按开始时间升序的会议"
+// @return        err              error               "规则错误或次数过多"
+func _SeriesOccurrences(series MeetingSeries) ([]MeetingInfo, error) {
+	rule, err := ParseRRule(series.RRule)
+	if err != nil {
+		return nil, err
+	}
+	exDates := make(map[string]bool)
+	if series.ExDates != "" {
+		for _, date := range strings.Split(series.ExDates, ",") {
+			exDates[date] = true
+		}
+	}
+	begins, err := rule.Expand(series.BeginAt, exDates, MeetingSeriesMaxOccurrences)
+	if err != nil {
+		return nil, err
+	}
+	if len(begins) == 0 {
+		return nil, fmt.Errorf("按这个规则不会产生任何会议")
+	}
+	duration := series.EndAt.Sub(series.BeginAt)
+	meetings := make([]MeetingInfo, 0, len(begins))
+	for _, begin := range begins {
+		meetings = append(meetings, MeetingInfo{
+			BeginAt:            begin,
+			EndAt:              begin.Add(duration),
+			MeetingDescription: series.MeetingDescription,
+			Geofence:           series.Geofence,
+			CreatedBy:          series.CreatedBy,
+			SeriesID:           series.ID,
+			OccurrenceDate:     begin.Format(time.DateOnly),
+		})
+	}
+	return meetings, nil
+}
+
+// @title         _InitSeriesMeetings
+// @description   为新生成的会议初始化会议数据库
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         GlobalPath       string              "指定数据存放在什么地方"
+// @param         GroupID          uint                "组织ID"
+// @param         Meetings         []MeetingInfo       "已经写入组织数据库的会议"
+// @return        err              error               "可能存在的错误"
+func _InitSeriesMeetings(GlobalPath string, GroupID uint, Meetings []MeetingInfo) error {
+	for _, meeting := range Meetings {
+		MeetingDatabase, err := InitMeeting(GlobalPath, GroupID, meeting.ID, true)
+		if err != nil {
+			return err
+		}
+		_CloseDatabase(MeetingDatabase)
+	}
+	return nil
+}
+
+// seriesPlan 修改重复会议时对已有会议的处理
+type seriesPlan struct {
+	// Update 需要更新时间、描述及围栏(已取消的恢复)的会议,ID为已有会议的ID,其它字段为新的值
+	Update []MeetingInfo
+	// Cancel 需要取消的会议ID
+	Cancel []uint
+	// Create 需要新建的会议
+	Create []MeetingInfo
+}
+
+// @title         planSeriesUpdate
+// @description   按日期对比已有的会议及按新规则生成的会议:已经开始的会议以及单独修改或取消过的会议不变,新规则中仍有的日期更新,不再有的日期取消,新增的日期创建
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Existing         []MeetingInfo       "重复会议已有的会议"
+// @param         Occurrences      []MeetingInfo       "按新规则生成的会议"
+// @param         Now              time.Time           "当前时间"
+// @return        plan             seriesPlan          "对已有会议的处理"
+func planSeriesUpdate(Existing []MeetingInfo, Occurrences []MeetingInfo, Now time.Time) seriesPlan {
+	plan := seriesPlan{Update: []MeetingInfo{}, Cancel: []uint{}, Create: []MeetingInfo{}}
+	byDate := make(map[string]MeetingInfo)
+	for _, occurrence := range Occurrences {
+		byDate[occurrence.OccurrenceDate] = occurrence
+	}
+	existingDates := make(map[string]bool)
+	for _, meeting := range Existing {
+		existingDates[meeting.OccurrenceDate] = true
+		if meeting.Detached || !meeting.BeginAt.After(Now) {
+			continue
+		}
+		occurrence, ok := byDate[meeting.OccurrenceDate]
+		if !ok {
+			if !meeting.Canceled {
+				plan.Cancel = append(plan.Cancel, meeting.ID)
+			}
+			continue
+		}
+		occurrence.ID = meeting.ID
+		plan.Update = append(plan.Update, occurrence)
+	}
+	for _, occurrence := range Occurrences {
+		if !existingDates[occurrence.OccurrenceDate] && occurrence.BeginAt.After(Now) {
+			plan.Create = append(plan.Create, occurrence)
+		}
+	}
+	return plan
+}
+
+// @title         Create_meeting_series
+// @description   安排重复会议,按RRULE一次生成所有会议及其会议数据库,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Create_meeting_series(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			// BeginAt EndAt 第一次会议的时间
+			BeginAt            time.Time
+			EndAt              time.Time
+			RRule              string
+			ExDates            []string
+			MeetingDescription string
+			Geofence           Geofence
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.BeginAt.IsZero() || request.EndAt.IsZero() {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if !request.EndAt.After(request.BeginAt) {
+			c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+			return
+		}
+		if err := request.Geofence.Validate(); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+		exDates, err := _ParseExDates(request.ExDates)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+
+		series := MeetingSeries{
+			BeginAt:            request.BeginAt.Local(),
+			EndAt:              request.EndAt.Local(),
+			RRule:              strings.ToUpper(strings.TrimSpace(request.RRule)),
+			ExDates:            exDates,
+			MeetingDescription: request.MeetingDescription,
+			Geofence:           request.Geofence,
+			CreatedBy:          _GetContextUserID(c),
+			CreatedAt:          time.Now().Unix(),
+		}
+		meetings, err := _SeriesOccurrences(series)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+
+		GroupDatabase := _GetContextGroupDatabase(c)
+		err = GroupDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&series).Error; err != nil {
+				return err
+			}
+			for i := range meetings {
+				meetings[i].SeriesID = series.ID
+				meetings[i].CreatedAt = series.CreatedAt
+			}
+			return tx.Create(&meetings).Error
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if err := _InitSeriesMeetings(GlobalPath, _GetContextGroupID(c), meetings); err != nil {
+			GroupDatabase.Where("series_id = ?", series.ID).Delete(&MeetingInfo{})
+			GroupDatabase.Delete(&series)
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": fmt.Sprintf("创建重复会议成功,共%d次", len(meetings)), "data": series, "Meetings": meetings})
+	}
+}
+
+// @title         Meeting_series
+// @description   列出组织内的重复会议,指定SeriesID时返回该重复会议及其所有会议,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Meeting_series() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			SeriesID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+
+		if request.SeriesID == 0 {
+			series := []MeetingSeries{}
+			if err := GroupDatabase.Order("id DESC").Find(&series).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+			c.JSON(200, gin.H{"code": 0, "message": "获取重复会议成功", "data": series})
+			return
+		}
+		var series MeetingSeries
+		if err := GroupDatabase.First(&series, request.SeriesID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 34, "message": "重复会议不存在"})
+			return
+		}
+		meetings := []MeetingInfo{}
+		if err := GroupDatabase.Where("series_id = ?", series.ID).Order("begin_at ASC").Find(&meetings).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取重复会议成功", "data": series, "Meetings": meetings})
+	}
+}
+
+// @title         Update_meeting_series
+// @description   修改整个重复会议,重新按规则生成尚未开始的会议:新规则中有的更新时间及描述(被取消的恢复),没有的取消,新增的创建;已经开始的会议以及单独修改或取消过的会议不变,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Update_meeting_series(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			SeriesID uint
+			// BeginAt EndAt 第一次会议的时间,用于修改钟点、时长或开始日期
+			BeginAt            *time.Time
+			EndAt              *time.Time
+			RRule              *string
+			ExDates            *[]string
+			MeetingDescription *string
+			// Geofence 传入空对象{}时取消围栏
+			Geofence *Geofence
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.SeriesID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+		var series MeetingSeries
+		if err := GroupDatabase.First(&series, request.SeriesID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 34, "message": "重复会议不存在"})
+			return
+		}
+		if series.Canceled {
+			c.JSON(400, gin.H{"code": 35, "message": "重复会议已取消"})
+			return
+		}
+
+		if request.BeginAt != nil {
+			series.BeginAt = request.BeginAt.Local()
+		}
+		if request.EndAt != nil {
+			series.EndAt = request.EndAt.Local()
+		}
+		if request.RRule != nil {
+			series.RRule = strings.ToUpper(strings.TrimSpace(*request.RRule))
+		}
+		if request.ExDates != nil {
+			exDates, err := _ParseExDates(*request.ExDates)
+			if err != nil {
+				c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+				return
+			}
+			series.ExDates = exDates
+		}
+		if request.MeetingDescription != nil {
+			series.MeetingDescription = *request.MeetingDescription
+		}
+		if request.Geofence != nil {
+			if err := request.Geofence.Validate(); err != nil {
+				c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+				return
+			}
+			series.Geofence = *request.Geofence
+		}
+		if !series.EndAt.After(series.BeginAt) {
+			c.JSON(400, gin.H{"code": 1, "message": "结束时间必须晚于开始时间"})
+			return
+		}
+		occurrences, err := _SeriesOccurrences(series)
+		if err != nil {
+			c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+			return
+		}
+
+		var existing []MeetingInfo
+		if err := GroupDatabase.Where("series_id = ?", series.ID).Find(&existing).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		now := time.Now()
+		plan := planSeriesUpdate(existing, occurrences, now)
+		created := plan.Create
+		err = GroupDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&series).Error; err != nil {
+				return err
+			}
+			for _, occurrence := range plan.Update {
+				updateData := map[string]interface{}{
+					"BeginAt":            occurrence.BeginAt,
+					"EndAt":              occurrence.EndAt,
+					"MeetingDescription": occurrence.MeetingDescription,
+					"Canceled":           false,
+				}
+				for column, value := range geofenceColumns(occurrence.Geofence) {
+					updateData[column] = value
+				}
+				if err := tx.Model(&MeetingInfo{}).Where("id = ?", occurrence.ID).Updates(updateData).Error; err != nil {
+					return err
+				}
+			}
+			if len(plan.Cancel) > 0 {
+				if err := tx.Model(&MeetingInfo{}).Where("id IN ?", plan.Cancel).Update("canceled", true).Error; err != nil {
+					return err
+				}
+			}
+			if len(created) == 0 {
+				return nil
+			}
+			for i := range created {
+				created[i].CreatedBy = _GetContextUserID(c)
+				created[i].CreatedAt = now.Unix()
+			}
+			return tx.Create(&created).Error
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		if err := _InitSeriesMeetings(GlobalPath, _GetContextGroupID(c), created); err != nil {
+			// 修改已经提交,会议数据库会在第一次使用时创建,这里只是提前初始化
+			log.Printf("Failed to init meetings of series %d: %v\n", series.ID, err)
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "修改重复会议成功", "data": series,
+			"Updated": len(plan.Update), "Created": len(created), "Canceled": len(plan.Cancel)})
+	}
+}
+
+// @title         Cancel_meeting_series
+// @description   取消整个重复会议,尚未开始的会议(包括单独修改过的)全部取消,已经开始的会议保留,需要先经过GroupMiddleware(CapManageMeetings)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Cancel_meeting_series() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			SeriesID uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.SeriesID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		GroupDatabase := _GetContextGroupDatabase(c)
+		var series MeetingSeries
+		if err := GroupDatabase.First(&series, request.SeriesID).Error; err != nil {
+			c.JSON(400, gin.H{"code": 34, "message": "重复会议不存在"})
+			return
+		}
+
+		var canceled int64
+		err := GroupDatabase.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&MeetingSeries{}).Where("id = ? AND canceled = ?", series.ID, false).Update("canceled", true)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			result = tx.Model(&MeetingInfo{}).
+				Where("series_id = ? AND canceled = ? AND begin_at > ?", series.ID, false, time.Now()).
+				Update("canceled", true)
+			canceled = result.RowsAffected
+			return result.Error
+		})
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(400, gin.H{"code": 35, "message": "重复会议已取消"})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已取消重复会议", "Canceled": canceled})
+	}
+}
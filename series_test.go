@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPlanSeriesUpdate(t *testing.T) {
+	now := time.Date(2026, 9, 10, 12, 0, 0, 0, time.Local)
+	meeting := func(id uint, date string, hour int) MeetingInfo {
+		day, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		begin := day.Add(time.Duration(hour) * time.Hour)
+		return MeetingInfo{ID: id, BeginAt: begin, EndAt: begin.Add(time.Hour), SeriesID: 1, OccurrenceDate: date}
+	}
+	ids := func(meetings []MeetingInfo) []uint {
+		result := []uint{}
+		for _, item := range meetings {
+			result = append(result, item.ID)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+		return result
+	}
+	dates := func(meetings []MeetingInfo) []string {
+		result := []string{}
+		for _, item := range meetings {
+			result = append(result, item.OccurrenceDate)
+		}
+		sort.Strings(result)
+		return result
+	}
+	equal := func(a []uint, b []uint) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	started := meeting(1, "2026-09-10", 8)
+	kept := meeting(2, "2026-09-11", 8)
+	dropped := meeting(3, "2026-09-12", 8)
+	restored := meeting(4, "2026-09-13", 8)
+	restored.Canceled = true
+	detachedKept := meeting(5, "2026-09-14", 8)
+	detachedKept.Detached = true
+	detachedDropped := meeting(6, "2026-09-15", 8)
+	detachedDropped.Detached = true
+	alreadyCanceled := meeting(7, "2026-09-16", 8)
+	alreadyCanceled.Canceled = true
+	past := meeting(8, "2026-09-09", 8)
+	existing := []MeetingInfo{started, kept, dropped, restored, detachedKept, detachedDropped, alreadyCanceled, past}
+
+	// 新规则:每天14点,去掉9-12、9-15、9-16,新增9-17、9-18,9-09及9-10已经开始
+	occurrences := []MeetingInfo{}
+	for _, date := range []string{"2026-09-08", "2026-09-09", "2026-09-10", "2026-09-11", "2026-09-13", "2026-09-14", "2026-09-17", "2026-09-18"} {
+		occurrence := meeting(0, date, 14)
+		occurrence.MeetingDescription = "new"
+		occurrences = append(occurrences, occurrence)
+	}
+
+	plan := planSeriesUpdate(existing, occurrences, now)
+
+	if got := ids(plan.Update); !equal(got, []uint{2, 4}) {
+		t.Errorf("Update = %v, want [2 4]", got)
+	}
+	for _, item := range plan.Update {
+		if item.BeginAt.Hour() != 14 || item.MeetingDescription != "new" {
+			t.Errorf("meeting %d updated to %v %q, want the new occurrence", item.ID, item.BeginAt, item.MeetingDescription)
+		}
+	}
+	sort.Slice(plan.Cancel, func(i, j int) bool { return plan.Cancel[i] < plan.Cancel[j] })
+	if !equal(plan.Cancel, []uint{3}) {
+		t.Errorf("Cancel = %v, want [3]", plan.Cancel)
+	}
+	// 9-08不属于已有的会议但已经过去,不创建
+	if got := dates(plan.Create); len(got) != 2 || got[0] != "2026-09-17" || got[1] != "2026-09-18" {
+		t.Errorf("Create = %v, want [2026-09-17 2026-09-18]", got)
+	}
+	for _, item := range plan.Create {
+		if item.ID != 0 {
+			t.Errorf("created meeting for %s has ID %d", item.OccurrenceDate, item.ID)
+		}
+	}
+}
+
+func TestPlanSeriesUpdateUnchanged(t *testing.T) {
+	now := time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)
+	series := MeetingSeries{
+		ID:      1,
+		BeginAt: time.Date(2026, 9, 1, 8, 0, 0, 0, time.Local),
+		EndAt:   time.Date(2026, 9, 1, 9, 40, 0, 0, time.Local),
+		RRule:   "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=32",
+		ExDates: "2026-10-01",
+	}
+	occurrences, err := _SeriesOccurrences(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 31 {
+		t.Fatalf("got %d occurrences, want 31", len(occurrences))
+	}
+	existing := make([]MeetingInfo, len(occurrences))
+	for i, occurrence := range occurrences {
+		occurrence.ID = uint(i + 1)
+		existing[i] = occurrence
+	}
+
+	plan := planSeriesUpdate(existing, occurrences, now)
+	if len(plan.Update) != 31 || len(plan.Cancel) != 0 || len(plan.Create) != 0 {
+		t.Errorf("got %d updates, %d cancels and %d creates, want 31, 0 and 0", len(plan.Update), len(plan.Cancel), len(plan.Create))
+	}
+}
@@ -0,0 +1,311 @@
+// @Title       session.go
+// @Description 放置登陆会话(短期access token + 轮换的refresh token)相关的网站入口函数以及工具函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 会话相关的时间参数
+var (
+	// AccessTokenTTL access token(即Token字段中的jwt)的有效期
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL refresh token的有效期,每次刷新都会重新计算
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// TokenSweepInterval 清理过期会话的间隔
+	TokenSweepInterval = time.Hour
+	// tokenTouchInterval LastUsedAt的最小更新间隔,避免每个请求都写数据库
+	tokenTouchInterval int64 = 60
+)
+
+// SessionInfo 会话列表接口返回的单个会话,不包含Token本身
+type SessionInfo struct {
+	ID               uint
+	Device           string
+	CreatedAt        int64
+	LastUsedAt       int64
+	RefreshExpiresAt int64
+	// Current 是否为发起请求的会话
+	Current bool
+}
+
+// @title         hashRefreshToken
+// @description   数据库中只存放refresh token的sha256,泄露数据库也无法用来刷新
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         refreshToken     string              "refresh token原文"
+// @return        hash             string              "十六进制sha256"
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// @title         _GetDevice
+// @description   确定会话的设备描述,请求中没有给出时使用User-Agent
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         c                *gin.Context        "gin上下文"
+// @param         device           string              "请求中给出的设备描述"
+// @return        device           string              "设备描述"
+func _GetDevice(c *gin.Context, device string) string {
+	if device == "" {
+		device = c.Request.UserAgent()
+	}
+	if len(device) > 128 {
+		device = device[:128]
+	}
+	return device
+}
+
+// @title         _IssueSession
+// @description   为用户创建新的登陆会话
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库(可以是事务)"
+// @param         UserID                        uint                "用户ID"
+// @param         Device                        string              "设备描述"
+// @return        token                         *Token              "会话记录(Token字段为access token)"
+// @return        refreshToken                  string              "refresh token原文(只在此时返回一次)"
+// @return        err                           error               "可能存在的错误"
+func _IssueSession(GlobalDatabase *gorm.DB, UserID uint, Device string) (*Token, string, error) {
+	now := time.Now()
+	accessToken, err := generateToken(UserID, GlobalJWTKeyring, now.Add(AccessTokenTTL))
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken := randomHex(32)
+	token := Token{
+		UserID:           UserID,
+		Token:            accessToken,
+		RefreshToken:     hashRefreshToken(refreshToken),
+		Device:           Device,
+		LastUsedAt:       now.Unix(),
+		ExpiresAt:        now.Add(AccessTokenTTL).Unix(),
+		RefreshExpiresAt: now.Add(RefreshTokenTTL).Unix(),
+	}
+	if err := GlobalDatabase.Create(&token).Error; err != nil {
+		return nil, "", err
+	}
+	return &token, refreshToken, nil
+}
+
+// @title         _SessionResponse
+// @description   登陆及刷新接口返回的会话数据
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         message          string              "返回信息"
+// @param         token            *Token              "会话记录"
+// @param         refreshToken     string              "refresh token原文"
+// @return        response         gin.H               "返回数据"
+func _SessionResponse(message string, token *Token, refreshToken string) gin.H {
+	return gin.H{
+		"code":             0,
+		"message":          message,
+		"Token":            token.Token,
+		"ExpiresAt":        token.ExpiresAt,
+		"RefreshToken":     refreshToken,
+		"RefreshExpiresAt": token.RefreshExpiresAt,
+		"UserID":           token.UserID,
+		"SessionID":        token.ID,
+	}
+}
+
+// @title         Refresh_token
+// @description   用refresh token换取新的access token,同时轮换refresh token
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Refresh_token(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			RefreshToken string
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		hashed := hashRefreshToken(request.RefreshToken)
+		now := time.Now()
+
+		var token Token
+		err := GlobalDatabase.Where("refresh_token = ?", hashed).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 已经轮换掉的refresh token再次出现,说明它可能被盗用了,撤销整个会话
+			result := GlobalDatabase.Where("previous_refresh_token = ?", hashed).Delete(&Token{})
+			if result.Error == nil && result.RowsAffected > 0 {
+				log.Printf("Revoked session because a rotated refresh token was reused\n")
+			}
+			c.JSON(401, gin.H{"code": 2, "message": "RefreshToken无效或已被撤销"})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+		if token.RefreshExpiresAt < now.Unix() {
+			GlobalDatabase.Delete(&Token{}, token.ID)
+			c.JSON(401, gin.H{"code": 3, "message": "RefreshToken已过期,请重新登陆"})
+			return
+		}
+
+		accessToken, err := generateToken(token.UserID, GlobalJWTKeyring, now.Add(AccessTokenTTL))
+		if err != nil {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+		refreshToken := randomHex(32)
+		updateData := map[string]interface{}{
+			"Token":                accessToken,
+			"RefreshToken":         hashRefreshToken(refreshToken),
+			"PreviousRefreshToken": hashed,
+			"LastUsedAt":           now.Unix(),
+			"ExpiresAt":            now.Add(AccessTokenTTL).Unix(),
+			"RefreshExpiresAt":     now.Add(RefreshTokenTTL).Unix(),
+		}
+		// 带上旧的refresh token作为条件,并发刷新时只有一个请求能成功
+		result := GlobalDatabase.Model(&Token{}).Where("id = ? AND refresh_token = ?", token.ID, hashed).Updates(updateData)
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(401, gin.H{"code": 2, "message": "RefreshToken无效或已被撤销"})
+			return
+		}
+		if err := GlobalDatabase.First(&token, token.ID).Error; err != nil {
+			c.JSON(500, gin.H{"code": 4, "message": "内部错误"})
+			return
+		}
+
+		c.JSON(200, _SessionResponse("刷新成功", &token, refreshToken))
+	}
+}
+
+// @title         Sessions
+// @description   列出当前用户所有未过期的会话,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sessions(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tokens []Token
+		err := GlobalDatabase.
+			Where("user_id = ? AND refresh_expires_at >= ?", _GetContextUserID(c), time.Now().Unix()).
+			Order("last_used_at DESC").
+			Find(&tokens).Error
+		if err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "获取会话列表失败"})
+			return
+		}
+
+		currentToken := _GetContextToken(c)
+		sessions := make([]SessionInfo, 0, len(tokens))
+		for _, token := range tokens {
+			sessions = append(sessions, SessionInfo{
+				ID:               token.ID,
+				Device:           token.Device,
+				CreatedAt:        token.CreatedAt,
+				LastUsedAt:       token.LastUsedAt,
+				RefreshExpiresAt: token.RefreshExpiresAt,
+				Current:          token.Token == currentToken,
+			})
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取会话列表成功", "data": sessions})
+	}
+}
+
+// @title         Revoke_session
+// @description   撤销当前用户的某个会话,需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Revoke_session(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID uint
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.ID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+
+		// 只能撤销自己的会话
+		result := GlobalDatabase.Where("id = ? AND user_id = ?", request.ID, _GetContextUserID(c)).Delete(&Token{})
+		if result.Error != nil {
+			c.JSON(500, gin.H{"code": 3, "message": "撤销会话失败"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(400, gin.H{"code": 2, "message": "会话不存在"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "撤销会话成功"})
+	}
+}
+
+// @title         Logout_all
+// @description   撤销当前用户的所有会话(在所有设备上退出登陆),需要先经过AuthMiddleware
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Logout_all(GlobalDatabase *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := _DeleteTokensByUserID(GlobalDatabase, _GetContextUserID(c)); err != nil {
+			c.JSON(500, gin.H{"code": 2, "message": "注销失败"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已在所有设备上注销"})
+	}
+}
+
+// @title         _TouchSession
+// @description   更新会话的最后使用时间,间隔小于tokenTouchInterval时跳过
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         token                         *Token              "会话记录"
+func _TouchSession(GlobalDatabase *gorm.DB, token *Token) {
+	now := time.Now().Unix()
+	if now-token.LastUsedAt < tokenTouchInterval {
+		return
+	}
+	if err := GlobalDatabase.Model(&Token{}).Where("id = ?", token.ID).Update("last_used_at", now).Error; err != nil {
+		log.Printf("Failed to update last_used_at of session %d: %v\n", token.ID, err)
+	}
+}
+
+// @title         _SweepExpiredTokens
+// @description   删除refresh token已过期的会话
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @return        count                         int64               "删除的会话数"
+// @return        err                           error               "可能存在的错误"
+func _SweepExpiredTokens(GlobalDatabase *gorm.DB) (int64, error) {
+	result := GlobalDatabase.Where("refresh_expires_at < ?", time.Now().Unix()).Delete(&Token{})
+	return result.RowsAffected, result.Error
+}
+
+// @title         StartTokenSweeper
+// @description   在后台定期清理过期的会话
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         Interval                      time.Duration       "清理间隔"
+func StartTokenSweeper(GlobalDatabase *gorm.DB, Interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(Interval)
+		defer ticker.Stop()
+		for {
+			count, err := _SweepExpiredTokens(GlobalDatabase)
+			if err != nil {
+				log.Printf("Failed to sweep expired tokens: %v\n", err)
+			} else if count > 0 {
+				log.Printf("Swept %d expired tokens\n", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
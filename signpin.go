@@ -0,0 +1,186 @@
+// @Title       signpin.go
+// @Description 放置PIN签到(组织者念出随机数字PIN,成员输入后签到)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// PIN签到的参数
+var (
+	SignPINDefaultLength = 4
+	SignPINMinLength     = 4
+	SignPINMaxLength     = 6
+	// SignPINMaxAttempts 每个用户在一次签到中最多可以输错PIN的次数,重新生成PIN后清零
+	SignPINMaxAttempts = 5
+)
+
+// signPINAlphabet PIN使用的字符,可以以0开头
+const signPINAlphabet = "0123456789"
+
+// PINAttempt PIN签到输错次数gorm对象,用于阻止穷举PIN
+type PINAttempt struct {
+	UserID       uint `gorm:"uniqueIndex:idx_pin_attempt_user_sign"`
+	SignID       uint `gorm:"uniqueIndex:idx_pin_attempt_user_sign"`
+	Failures     int
+	LastFailedAt int64
+}
+
+// @title         _CheckSignPIN
+// @description   校验成员提交的PIN并记录输错次数,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         sign                                  Sign                "PIN签到"
+// @param         PIN                                   string              "成员提交的PIN"
+// @return        ok                                    bool                "PIN是否正确"
+func _CheckSignPIN(c *gin.Context, MeetingDatabase *gorm.DB, sign Sign, PIN string) bool {
+	attempt := PINAttempt{UserID: _GetContextUserID(c), SignID: sign.ID}
+	if err := MeetingDatabase.Where(attempt).FirstOrCreate(&attempt).Error; err != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return false
+	}
+	// 比较之前先占用一次机会,并发提交时也不会超过次数限制
+	where := MeetingDatabase.Model(&PINAttempt{}).Where("user_id = ? AND sign_id = ?", attempt.UserID, attempt.SignID).Session(&gorm.Session{})
+	result := where.Where("failures < ?", SignPINMaxAttempts).Update("failures", gorm.Expr("failures + 1"))
+	if result.Error != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(400, gin.H{"code": 14, "message": "PIN输错次数过多,请联系组织者"})
+		return false
+	}
+	if sign.PIN != "" && subtle.ConstantTimeCompare([]byte(PIN), []byte(sign.PIN)) == 1 {
+		// PIN正确时退还占用的机会
+		if err := where.Update("failures", gorm.Expr("failures - 1")).Error; err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return false
+		}
+		return true
+	}
+
+	if err := where.Update("last_failed_at", time.Now().Unix()).Error; err != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return false
+	}
+	if err := where.Select("failures").Scan(&attempt.Failures).Error; err != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return false
+	}
+	c.JSON(400, gin.H{"code": 13, "message": "PIN错误", "RemainingAttempts": SignPINMaxAttempts - attempt.Failures})
+	return false
+}
+
+// @title         _OpenPINSign
+// @description   读取未结束的PIN签到,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         MeetingDatabase                       *gorm.DB            "会议数据库"
+// @param         SignID                                uint                "签到ID"
+// @return        sign                                  Sign                "签到"
+// @return        ok                                    bool                "是否成功"
+func _OpenPINSign(c *gin.Context, MeetingDatabase *gorm.DB, SignID uint) (Sign, bool) {
+	var sign Sign
+	if err := MeetingDatabase.First(&sign, SignID).Error; err != nil {
+		c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+		return Sign{}, false
+	}
+	if sign.Mode != SignModePIN {
+		c.JSON(400, gin.H{"code": 12, "message": "该签到不是PIN签到"})
+		return Sign{}, false
+	}
+	if sign.Status(time.Now()) == SignStatusClosed {
+		c.JSON(400, gin.H{"code": 9, "message": "签到已结束"})
+		return Sign{}, false
+	}
+	return sign, true
+}
+
+// @title         Sign_pin
+// @description   组织者查看PIN签到当前的PIN,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sign_pin(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		sign, ok := _OpenPINSign(c, MeetingDatabase, request.SignID)
+		if !ok {
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取PIN成功", "PIN": sign.PIN})
+	}
+}
+
+// @title         Regenerate_sign_pin
+// @description   签到过程中重新生成PIN(比如PIN被传出教室),旧PIN立即失效,所有成员的输错次数清零,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Regenerate_sign_pin(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			// PINLength 新PIN的位数,为0时沿用原来的位数
+			PINLength int
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, request.MeetingID)
+		if !ok {
+			return
+		}
+		defer _CloseDatabase(MeetingDatabase)
+
+		sign, ok := _OpenPINSign(c, MeetingDatabase, request.SignID)
+		if !ok {
+			return
+		}
+		length := request.PINLength
+		if length == 0 {
+			length = len(sign.PIN)
+		}
+		if length < SignPINMinLength || length > SignPINMaxLength {
+			c.JSON(400, gin.H{"code": 1, "message": "PIN位数不合法"})
+			return
+		}
+
+		pin := randomString(signPINAlphabet, length)
+		err := MeetingDatabase.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&Sign{}).Where("id = ?", sign.ID).Update("pin", pin).Error; err != nil {
+				return err
+			}
+			return tx.Where("sign_id = ?", sign.ID).Delete(&PINAttempt{}).Error
+		})
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "已重新生成PIN", "PIN": pin})
+	}
+}
@@ -0,0 +1,213 @@
+// @Title       signqr.go
+// @Description 放置二维码签到的动态二维码内容(按时间片轮换的HMAC签名)以及显示二维码的网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// 动态二维码刷新间隔(秒)
+var (
+	SignQRDefaultInterval = 10
+	SignQRMinInterval     = 5
+	SignQRMaxInterval     = 300
+	// SignQRAcceptedSteps 除当前时间片外还接受之前几个时间片的二维码,用于容忍扫码及网络延迟
+	SignQRAcceptedSteps = 1
+	// SignQRImageScale 二维码图片默认每个模块的像素数
+	SignQRImageScale = 8
+)
+
+// signQRPrefix 二维码内容的前缀及版本,内容为 RC1.<GroupID>.<MeetingID>.<SignID>.<时间片>.<签名>
+const signQRPrefix = "RC1"
+
+// @title         signQRMAC
+// @description   计算某个时间片的二维码签名
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Secret           string              "签到的HMAC密钥"
+// @param         Message          string              "签名前的二维码内容"
+// @return        mac              string              "截断为80位的十六进制签名"
+func signQRMAC(Secret string, Message string) string {
+	mac := hmac.New(sha256.New, []byte(Secret))
+	mac.Write([]byte(Message))
+	return hex.EncodeToString(mac.Sum(nil)[:10])
+}
+
+// @title         signQRPayload
+// @description   生成签到在某一时刻的二维码内容
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         GroupID          uint                "组织ID"
+// @param         MeetingID        uint                "会议ID"
+// @param         sign             Sign                "签到"
+// @param         Now              time.Time           "当前时间"
+// @return        payload          string              "二维码内容"
+// @return        expiresAt        time.Time           "下一次刷新的时间"
+func signQRPayload(GroupID uint, MeetingID uint, sign Sign, Now time.Time) (string, time.Time) {
+	interval := int64(sign.QRInterval)
+	step := Now.Unix() / interval
+	message := fmt.Sprintf("%s.%d.%d.%d.%d", signQRPrefix, GroupID, MeetingID, sign.ID, step)
+	return message + "." + signQRMAC(sign.Secret, message), time.Unix((step+1)*interval, 0)
+}
+
+// @title         verifySignQRPayload
+// @description   校验二维码内容是否属于这次签到且在当前或之前SignQRAcceptedSteps个时间片内
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         GroupID          uint                "组织ID"
+// @param         MeetingID        uint                "会议ID"
+// @param         sign             Sign                "签到"
+// @param         Payload          string              "成员提交的二维码内容"
+// @param         Now              time.Time           "当前时间"
+// @return        valid            bool                "是否有效"
+func verifySignQRPayload(GroupID uint, MeetingID uint, sign Sign, Payload string, Now time.Time) bool {
+	if sign.Secret == "" || sign.QRInterval <= 0 {
+		return false
+	}
+	index := strings.LastIndex(Payload, ".")
+	if index < 0 {
+		return false
+	}
+	message, mac := Payload[:index], Payload[index+1:]
+	parts := strings.Split(message, ".")
+	if len(parts) != 5 || parts[0] != signQRPrefix {
+		return false
+	}
+	expected := fmt.Sprintf("%s.%d.%d.%d.", signQRPrefix, GroupID, MeetingID, sign.ID)
+	if !strings.HasPrefix(message, expected) {
+		return false
+	}
+	step, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return false
+	}
+	current := Now.Unix() / int64(sign.QRInterval)
+	if step > current || step < current-int64(SignQRAcceptedSteps) {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(signQRMAC(sign.Secret, message)))
+}
+
+// @title         _OpenQRSign
+// @description   读取正在进行的二维码签到,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         MeetingID                             uint                "会议ID"
+// @param         SignID                                uint                "签到ID"
+// @return        sign                                  Sign                "签到"
+// @return        ok                                    bool                "是否成功"
+func _OpenQRSign(c *gin.Context, GlobalPath string, MeetingID uint, SignID uint) (Sign, bool) {
+	_, MeetingDatabase, ok := _OpenMeeting(c, GlobalPath, MeetingID)
+	if !ok {
+		return Sign{}, false
+	}
+	defer _CloseDatabase(MeetingDatabase)
+
+	var sign Sign
+	if err := MeetingDatabase.First(&sign, SignID).Error; err != nil {
+		c.JSON(400, gin.H{"code": 7, "message": "签到不存在"})
+		return Sign{}, false
+	}
+	if sign.Mode != SignModeQR {
+		c.JSON(400, gin.H{"code": 12, "message": "该签到不是二维码签到"})
+		return Sign{}, false
+	}
+	switch sign.Status(time.Now()) {
+	case SignStatusScheduled:
+		c.JSON(400, gin.H{"code": 8, "message": "签到尚未开始"})
+		return Sign{}, false
+	case SignStatusClosed:
+		c.JSON(400, gin.H{"code": 9, "message": "签到已结束"})
+		return Sign{}, false
+	}
+	return sign, true
+}
+
+// @title         Sign_qr_code
+// @description   获取二维码签到当前的二维码内容,组织者的屏幕应在ExpiresAt前重新获取,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sign_qr_code(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		sign, ok := _OpenQRSign(c, GlobalPath, request.MeetingID, request.SignID)
+		if !ok {
+			return
+		}
+
+		payload, expiresAt := signQRPayload(_GetContextGroupID(c), request.MeetingID, sign, time.Now())
+		c.JSON(200, gin.H{"code": 0, "message": "获取二维码成功", "Payload": payload, "ExpiresAt": expiresAt, "Interval": sign.QRInterval})
+	}
+}
+
+// @title         Sign_qr_image
+// @description   直接输出二维码签到当前的二维码图片(PNG或SVG),响应头X-QR-Expires-At为下一次刷新的时间,需要先经过GroupMiddleware(CapStartSign)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Sign_qr_image(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MeetingID uint
+			SignID    uint
+			// Format png(默认)或svg
+			Format string
+			// Scale 每个模块的像素数
+			Scale int
+		}
+		if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil || request.MeetingID == 0 || request.SignID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return
+		}
+		if request.Format != "" && request.Format != "png" && request.Format != "svg" {
+			c.JSON(400, gin.H{"code": 1, "message": "Format只能是png或svg"})
+			return
+		}
+		if request.Scale <= 0 {
+			request.Scale = SignQRImageScale
+		} else if request.Scale > 32 {
+			request.Scale = 32
+		}
+		sign, ok := _OpenQRSign(c, GlobalPath, request.MeetingID, request.SignID)
+		if !ok {
+			return
+		}
+
+		payload, expiresAt := signQRPayload(_GetContextGroupID(c), request.MeetingID, sign, time.Now())
+		qr, err := EncodeQRCode([]byte(payload))
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-QR-Expires-At", strconv.FormatInt(expiresAt.Unix(), 10))
+		if request.Format == "svg" {
+			c.Data(200, "image/svg+xml", []byte(qr.SVG(request.Scale)))
+			return
+		}
+		image, err := qr.PNG(request.Scale)
+		if err != nil {
+			c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+			return
+		}
+		c.Data(200, "image/png", image)
+	}
+}
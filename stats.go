@@ -0,0 +1,385 @@
+// @Title       stats.go
+// @Description 放置汇总组织内各会议数据库计算考勤统计(出勤率、迟到及缺勤次数、连续出勤)的工具函数以及网站入口函数
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// 考勤统计的时间范围
+var (
+	// AttendanceStatsDefaultDays 没有指定开始时间时统计最近多少天
+	AttendanceStatsDefaultDays = 180
+	// AttendanceStatsMaxDays 一次最多统计多少天
+	AttendanceStatsMaxDays = 400
+)
+
+// MemberStatus 成员在一次会议中的考勤状态,不包含每次签到的考勤
+type MemberStatus struct {
+	UserID uint
+	Status string
+}
+
+// MeetingAttendanceRecord 一次会议及其中每个成员的考勤
+type MeetingAttendanceRecord struct {
+	Meeting    MeetingInfo
+	Attendance []MemberStatus
+}
+
+// AttendanceStats 考勤统计,尚未确定(pending)的考勤不计入
+type AttendanceStats struct {
+	// Meetings 计入统计的考勤数
+	Meetings  int
+	Present   int
+	Late      int
+	Absent    int
+	Excused   int
+	LeftEarly int
+	// Rate 出勤率 = (present+late+left_early)/(Meetings-excused),没有需要出勤的会议时为0
+	Rate float64
+}
+
+// MemberAttendanceStats 成员的考勤统计
+type MemberAttendanceStats struct {
+	UserID uint
+	AttendanceStats
+	// CurrentStreak 截至最近一次会议连续出勤的次数,请假不中断也不累加
+	CurrentStreak int
+	// LongestStreak 最长连续出勤次数
+	LongestStreak int
+}
+
+// MeetingAttendanceStats 一次会议的考勤统计
+type MeetingAttendanceStats struct {
+	MeetingID          uint
+	BeginAt            time.Time
+	EndAt              time.Time
+	MeetingDescription string
+	AttendanceStats
+}
+
+// @title         add
+// @description   把一个会议考勤状态计入统计
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Status           string              "会议的考勤状态"
+func (stats *AttendanceStats) add(Status string) {
+	switch Status {
+	case AttendancePresent:
+		stats.Present++
+	case AttendanceLate:
+		stats.Late++
+	case AttendanceAbsent:
+		stats.Absent++
+	case AttendanceExcused:
+		stats.Excused++
+	case AttendanceLeftEarly:
+		stats.LeftEarly++
+	default:
+		return
+	}
+	stats.Meetings++
+	if expected := stats.Meetings - stats.Excused; expected > 0 {
+		stats.Rate = math.Round(float64(stats.Present+stats.Late+stats.LeftEarly)/float64(expected)*10000) / 10000
+	}
+}
+
+// @title         _StatsRange
+// @description   规范化统计的时间范围,结束时间默认为现在,开始时间默认为结束时间前AttendanceStatsDefaultDays天
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         From             *time.Time          "开始时间"
+// @param         To               *time.Time          "结束时间"
+// @return        from             time.Time           "开始时间"
+// @return        to               time.Time           "结束时间"
+// @return        err              error               "结束时间不晚于开始时间或范围过大"
+func _StatsRange(From *time.Time, To *time.Time) (time.Time, time.Time, error) {
+	to := time.Now()
+	if To != nil {
+		to = To.Local()
+	}
+	from := to.AddDate(0, 0, -AttendanceStatsDefaultDays)
+	if From != nil {
+		from = From.Local()
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("结束时间必须晚于开始时间")
+	}
+	if to.Sub(from) > time.Duration(AttendanceStatsMaxDays)*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("一次最多统计%d天", AttendanceStatsMaxDays)
+	}
+	return from, to, nil
+}
+
+// @title         _EachMeetingAttendance
+// @description   依次加载组织内开始时间在[From, To)之间且没有取消的会议的会议数据库,计算每次会议的考勤后交给Visit,一次只保留一次会议的考勤
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         From                                  time.Time           "开始时间"
+// @param         To                                    time.Time           "结束时间"
+// @param         Visit                                 func(int, MeetingInfo, []MemberAttendance) error "按会议开始时间升序调用,参数为会议的序号、会议及考勤"
+// @return        meetings                              []MeetingInfo       "按会议开始时间升序的会议"
+// @return        err                                   error               "可能存在的错误"
+func _EachMeetingAttendance(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, From time.Time, To time.Time, Visit func(int, MeetingInfo, []MemberAttendance) error) ([]MeetingInfo, error) {
+	var meetings []MeetingInfo
+	if err := GroupDatabase.Where("canceled = ? AND begin_at >= ? AND begin_at < ?", false, From, To).
+		Order("begin_at ASC").Order("id ASC").Find(&meetings).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, meeting := range meetings {
+		excused, err := _ExcusedUserIDs(GroupDatabase, meeting)
+		if err != nil {
+			return nil, err
+		}
+		MeetingDatabase, err := InitMeeting(GlobalPath, GroupID, meeting.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		attendance, err := _MeetingAttendance(GroupDatabase, MeetingDatabase, meeting, excused, now)
+		_CloseDatabase(MeetingDatabase)
+		if err != nil {
+			return nil, err
+		}
+		if err := Visit(i, meeting, attendance); err != nil {
+			return nil, err
+		}
+	}
+	return meetings, nil
+}
+
+// @title         _GroupAttendance
+// @description   计算组织内开始时间在[From, To)之间且没有取消的每次会议的考勤,只保留每个成员的考勤状态
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         From                                  time.Time           "开始时间"
+// @param         To                                    time.Time           "结束时间"
+// @return        records                               []MeetingAttendanceRecord "按会议开始时间升序的考勤"
+// @return        err                                   error               "可能存在的错误"
+func _GroupAttendance(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, From time.Time, To time.Time) ([]MeetingAttendanceRecord, error) {
+	records := []MeetingAttendanceRecord{}
+	_, err := _EachMeetingAttendance(GlobalPath, GroupDatabase, GroupID, From, To, func(_ int, meeting MeetingInfo, attendance []MemberAttendance) error {
+		record := MeetingAttendanceRecord{Meeting: meeting, Attendance: make([]MemberStatus, 0, len(attendance))}
+		for _, member := range attendance {
+			record.Attendance = append(record.Attendance, MemberStatus{UserID: member.UserID, Status: member.Status})
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// @title         addMemberStatus
+// @description   把成员在一次会议中的考勤计入统计,必须按会议开始时间的顺序调用以计算连续出勤次数
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Stats            map[uint]*MemberAttendanceStats "每个成员的考勤统计"
+// @param         UserID           uint                "用户ID"
+// @param         Status           string              "考勤状态"
+func addMemberStatus(Stats map[uint]*MemberAttendanceStats, UserID uint, Status string) {
+	item := Stats[UserID]
+	if item == nil {
+		item = &MemberAttendanceStats{UserID: UserID}
+		Stats[UserID] = item
+	}
+	item.add(Status)
+	switch Status {
+	case AttendancePresent, AttendanceLate, AttendanceLeftEarly:
+		item.CurrentStreak++
+		if item.CurrentStreak > item.LongestStreak {
+			item.LongestStreak = item.CurrentStreak
+		}
+	case AttendanceAbsent:
+		item.CurrentStreak = 0
+	}
+}
+
+// @title         memberStats
+// @description   按会议开始时间的顺序统计每个成员的考勤及连续出勤次数
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Records          []MeetingAttendanceRecord "按会议开始时间升序的考勤"
+// @return        stats            map[uint]*MemberAttendanceStats "每个成员的考勤统计"
+func memberStats(Records []MeetingAttendanceRecord) map[uint]*MemberAttendanceStats {
+	stats := make(map[uint]*MemberAttendanceStats)
+	for _, record := range Records {
+		for _, member := range record.Attendance {
+			addMemberStatus(stats, member.UserID, member.Status)
+		}
+	}
+	return stats
+}
+
+// @title         _StatsRequest
+// @description   解析统计接口共用的请求参数并计算考勤,失败时已经写好返回值,调用方直接return即可
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         UserID                                *uint               "不为nil时把要统计的成员的UserID写入"
+// @return        records                               []MeetingAttendanceRecord "按会议开始时间升序的考勤"
+// @return        from                                  time.Time           "开始时间"
+// @return        to                                    time.Time           "结束时间"
+// @return        ok                                    bool                "是否成功"
+func _StatsRequest(c *gin.Context, GlobalPath string, UserID *uint) ([]MeetingAttendanceRecord, time.Time, time.Time, bool) {
+	var request struct {
+		From   *time.Time
+		To     *time.Time
+		UserID uint
+	}
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+		return nil, time.Time{}, time.Time{}, false
+	}
+	from, to, err := _StatsRange(request.From, request.To)
+	if err != nil {
+		c.JSON(400, gin.H{"code": 1, "message": err.Error()})
+		return nil, time.Time{}, time.Time{}, false
+	}
+	if UserID != nil {
+		if request.UserID == 0 {
+			c.JSON(400, gin.H{"code": 1, "message": "参数错误"})
+			return nil, time.Time{}, time.Time{}, false
+		}
+		*UserID = request.UserID
+	}
+	records, err := _GroupAttendance(GlobalPath, _GetContextGroupDatabase(c), _GetContextGroupID(c), from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+		return nil, time.Time{}, time.Time{}, false
+	}
+	return records, from, to, true
+}
+
+// @title         Attendance_stats
+// @description   统计组织在一段时间内的考勤,返回组织整体、每次会议及每个成员的统计,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalDatabase                *gorm.DB            "全局数据库"
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Attendance_stats(GlobalDatabase *gorm.DB, GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		records, from, to, ok := _StatsRequest(c, GlobalPath, nil)
+		if !ok {
+			return
+		}
+
+		var group AttendanceStats
+		meetings := make([]MeetingAttendanceStats, 0, len(records))
+		for _, record := range records {
+			item := MeetingAttendanceStats{
+				MeetingID:          record.Meeting.ID,
+				BeginAt:            record.Meeting.BeginAt,
+				EndAt:              record.Meeting.EndAt,
+				MeetingDescription: record.Meeting.MeetingDescription,
+			}
+			for _, member := range record.Attendance {
+				item.add(member.Status)
+				group.add(member.Status)
+			}
+			meetings = append(meetings, item)
+		}
+
+		stats := memberStats(records)
+		userIDs := make([]uint, 0, len(stats))
+		for userID := range stats {
+			userIDs = append(userIDs, userID)
+		}
+		sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+		var users []UserInfo
+		if len(userIDs) > 0 {
+			if err := GlobalDatabase.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+				c.JSON(500, gin.H{"code": 5, "message": "内部错误"})
+				return
+			}
+		}
+		userMap := make(map[uint]UserInfo)
+		for _, user := range users {
+			userMap[user.ID] = user
+		}
+		members := make([]gin.H, 0, len(userIDs))
+		for _, userID := range userIDs {
+			members = append(members, gin.H{
+				"Name":     userMap[userID].Name,
+				"NickName": userMap[userID].NickName,
+				"Stats":    stats[userID],
+			})
+		}
+		c.JSON(200, gin.H{"code": 0, "message": "获取考勤统计成功", "From": from, "To": to,
+			"Group": group, "Meetings": meetings, "Members": members})
+	}
+}
+
+// @title         _MemberStatsResponse
+// @description   返回一个成员的考勤统计及每次会议的考勤
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         c                                     *gin.Context        "gin上下文"
+// @param         Records                               []MeetingAttendanceRecord "按会议开始时间升序的考勤"
+// @param         UserID                                uint                "用户ID"
+// @param         From                                  time.Time           "开始时间"
+// @param         To                                    time.Time           "结束时间"
+func _MemberStatsResponse(c *gin.Context, Records []MeetingAttendanceRecord, UserID uint, From time.Time, To time.Time) {
+	stats := memberStats(Records)[UserID]
+	if stats == nil {
+		stats = &MemberAttendanceStats{UserID: UserID}
+	}
+	history := make([]gin.H, 0, len(Records))
+	for _, record := range Records {
+		for _, member := range record.Attendance {
+			if member.UserID == UserID {
+				history = append(history, gin.H{
+					"MeetingID":          record.Meeting.ID,
+					"BeginAt":            record.Meeting.BeginAt,
+					"MeetingDescription": record.Meeting.MeetingDescription,
+					"Status":             member.Status,
+				})
+			}
+		}
+	}
+	c.JSON(200, gin.H{"code": 0, "message": "获取考勤统计成功", "From": From, "To": To, "data": stats, "History": history})
+}
+
+// @title         Member_attendance_stats
+// @description   统计某个成员在一段时间内的考勤,需要先经过GroupMiddleware(CapViewAttendance)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func Member_attendance_stats(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userID uint
+		records, from, to, ok := _StatsRequest(c, GlobalPath, &userID)
+		if !ok {
+			return
+		}
+		_MemberStatsResponse(c, records, userID, from, to)
+	}
+}
+
+// @title         My_attendance_stats
+// @description   统计自己在一段时间内的考勤,需要先经过GroupMiddleware(CapViewGroup)
+// @auth          DataEraserC                           (2026/10/17   15:00)
+// @param         GlobalPath                    string              "指定数据存放在什么地方"
+// @return        匿名函数                      gin.HandlerFunc     "gin消息中间件"
+func My_attendance_stats(GlobalPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		records, from, to, ok := _StatsRequest(c, GlobalPath, nil)
+		if !ok {
+			return
+		}
+		_MemberStatsResponse(c, records, _GetContextUserID(c), from, to)
+	}
+}
@@ -8,6 +8,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log"
+	"os"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
@@ -30,7 +32,21 @@ type MemberOf struct {
 // @return        UserDatabase                          *gorm.DB            "用户数据库"
 // @return        err                                   error               "可能存在的错误"
 func InitUser(GlobalPath string, UserID uint, SafeMode bool) (*gorm.DB, error) {
-	UserDatabase, err := gorm.Open(sqlite.Open(fmt.Sprintf("%s/user/%d/database.db", GlobalPath, UserID)), &gorm.Config{})
+	UserDataPath := fmt.Sprintf("%s/user/%d", GlobalPath, UserID)
+	// 初始化UserDataPath文件夹
+	if _, err := os.Stat(UserDataPath); os.IsNotExist(err) {
+		// UserDataPath不存在，创建UserDataPath
+		err := os.MkdirAll(UserDataPath, 0755)
+		if err != nil {
+			log.Printf("Failed to create user directory: %v\n", err)
+		} else {
+			log.Println("User directory created successfully!")
+		}
+	} else if err != nil {
+		log.Printf("Error checking user directory: %v\n", err)
+	}
+
+	UserDatabase, err := gorm.Open(sqlite.Open(UserDataPath+"/database.db"), &gorm.Config{})
 	if err != nil {
 		return nil, errors.New("failed to connect database")
 	}
@@ -42,3 +58,285 @@ func InitUser(GlobalPath string, UserID uint, SafeMode bool) (*gorm.DB, error) {
 	}
 	return UserDatabase, nil
 }
+
+// @title         _MergeUser
+// @description   把重复的用户FromUserID合并进保留的用户IntoUserID,包括登陆方式、空缺的个人信息以及用户数据库中的组织,最后删除FromUserID
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库"
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         FromUserID                            uint                "被合并(删除)的用户ID"
+// @param         IntoUserID                            uint                "保留的用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _MergeUser(GlobalDatabase *gorm.DB, GlobalPath string, FromUserID uint, IntoUserID uint) error {
+	if FromUserID == IntoUserID {
+		return errors.New("不能把用户合并进自己")
+	}
+	var fromUser, intoUser UserInfo
+	if err := GlobalDatabase.First(&fromUser, FromUserID).Error; err != nil {
+		return fmt.Errorf("找不到用户%d: %w", FromUserID, err)
+	}
+	if err := GlobalDatabase.First(&intoUser, IntoUserID).Error; err != nil {
+		return fmt.Errorf("找不到用户%d: %w", IntoUserID, err)
+	}
+
+	// 先迁移各个数据库文件中的记录,中途失败时FromUserID仍然存在,可以重新执行
+	if err := _MergeUserDatabase(GlobalPath, FromUserID, IntoUserID); err != nil {
+		return err
+	}
+
+	err := GlobalDatabase.Transaction(func(tx *gorm.DB) error {
+		// 保留的用户缺少的个人信息用被合并用户的补上
+		updateData := make(map[string]interface{})
+		fillString := func(column string, into string, from string) {
+			if into == "" && from != "" {
+				updateData[column] = from
+			}
+		}
+		fillString("Avatar", intoUser.Avatar, fromUser.Avatar)
+		fillString("Name", intoUser.Name, fromUser.Name)
+		fillString("NickName", intoUser.NickName, fromUser.NickName)
+		fillString("Gender", intoUser.Gender, fromUser.Gender)
+		fillString("Collage", intoUser.Collage, fromUser.Collage)
+		fillString("Majar", intoUser.Majar, fromUser.Majar)
+		fillString("PhoneNumber", intoUser.PhoneNumber, fromUser.PhoneNumber)
+		fillString("RegistrationNumber", intoUser.RegistrationNumber, fromUser.RegistrationNumber)
+		// 占位用户的学号来自组织者导入的名单,认领时已经核对过
+		if (fromUser.Placeholder || fromUser.RegistrationVerified) && fromUser.RegistrationNumber != "" &&
+			(intoUser.RegistrationNumber == "" || intoUser.RegistrationNumber == fromUser.RegistrationNumber) {
+			updateData["RegistrationVerified"] = true
+		}
+		if intoUser.Grade == 0 && fromUser.Grade != 0 {
+			updateData["Grade"] = fromUser.Grade
+		}
+		if len(updateData) > 0 {
+			if err := tx.Model(&UserInfo{}).Where("id = ?", IntoUserID).Updates(updateData).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := _MergeLogin(tx, FromUserID, IntoUserID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&CreateGroupRequest{}).Where("user_id = ?", FromUserID).Update("user_id", IntoUserID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", FromUserID).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", FromUserID).Delete(&PlaceholderClaim{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&UserInfo{}, FromUserID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(fmt.Sprintf("%s/user/%d", GlobalPath, FromUserID)); err != nil {
+		log.Printf("Failed to remove user directory of %d: %v\n", FromUserID, err)
+	}
+	return nil
+}
+
+// @title         _MergeLogin
+// @description   合并两个用户的登陆方式,保留的用户没有的用户名密码或微信从被合并的用户处移过来
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalDatabase                        *gorm.DB            "全局数据库(可以是事务)"
+// @param         FromUserID                            uint                "被合并(删除)的用户ID"
+// @param         IntoUserID                            uint                "保留的用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _MergeLogin(GlobalDatabase *gorm.DB, FromUserID uint, IntoUserID uint) error {
+	var fromLogin, intoLogin Login
+	err := GlobalDatabase.Where("user_id = ?", FromUserID).First(&fromLogin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = GlobalDatabase.Where("user_id = ?", IntoUserID).First(&intoLogin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 保留的用户没有任何登陆方式,直接接管
+		return GlobalDatabase.Model(&Login{}).Where("user_id = ?", FromUserID).Update("user_id", IntoUserID).Error
+	} else if err != nil {
+		return err
+	}
+
+	// 先删除被合并用户的Login,避免用户名和OpenID违反唯一约束
+	if err := GlobalDatabase.Where("user_id = ?", FromUserID).Delete(&Login{}).Error; err != nil {
+		return err
+	}
+	updateData := make(map[string]interface{})
+	if intoLogin.Username == "" && fromLogin.Username != "" {
+		updateData["Username"] = fromLogin.Username
+		updateData["Password"] = fromLogin.Password
+	}
+	if intoLogin.OpenID == "" && fromLogin.OpenID != "" {
+		updateData["OpenID"] = fromLogin.OpenID
+		updateData["SessionKey"] = fromLogin.SessionKey
+		updateData["UnionID"] = fromLogin.UnionID
+	}
+	if len(updateData) == 0 {
+		return nil
+	}
+	return GlobalDatabase.Model(&Login{}).Where("user_id = ?", IntoUserID).Updates(updateData).Error
+}
+
+// @title         _MergeUserDatabase
+// @description   把被合并用户的用户数据库(加入的组织)并入保留的用户,并修改对应组织数据库中的成员记录及考勤等记录
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         FromUserID                            uint                "被合并(删除)的用户ID"
+// @param         IntoUserID                            uint                "保留的用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _MergeUserDatabase(GlobalPath string, FromUserID uint, IntoUserID uint) error {
+	if _, err := os.Stat(fmt.Sprintf("%s/user/%d/database.db", GlobalPath, FromUserID)); os.IsNotExist(err) {
+		// 被合并的用户没有加入过任何组织
+		return nil
+	}
+
+	FromUserDatabase, err := InitUser(GlobalPath, FromUserID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(FromUserDatabase)
+	IntoUserDatabase, err := InitUser(GlobalPath, IntoUserID, true)
+	if err != nil {
+		return err
+	}
+	defer _CloseDatabase(IntoUserDatabase)
+
+	var memberOfs []MemberOf
+	if err := FromUserDatabase.Find(&memberOfs).Error; err != nil {
+		return err
+	}
+	for _, memberOf := range memberOfs {
+		GroupDatabase, err := InitGroup(GlobalPath, memberOf.GroupID, true)
+		if err != nil {
+			return err
+		}
+		var count int64
+		err = _MergeGroupRecords(GlobalPath, GroupDatabase, memberOf.GroupID, FromUserID, IntoUserID)
+		if err == nil {
+			err = GroupDatabase.Model(&MemberInfo{}).Where("user_id = ?", IntoUserID).Count(&count).Error
+		}
+		if err == nil {
+			if count > 0 {
+				// 两个账号都在该组织中,保留原有的成员记录,加入时间取较早的
+				var from []MemberInfo
+				err = GroupDatabase.Where("user_id = ?", FromUserID).Find(&from).Error
+				if err == nil && len(from) > 0 {
+					err = GroupDatabase.Model(&MemberInfo{}).Where("user_id = ? AND joined_at > ?", IntoUserID, from[0].JoinedAt).
+						Update("joined_at", from[0].JoinedAt).Error
+				}
+				if err == nil {
+					err = GroupDatabase.Where("user_id = ?", FromUserID).Delete(&MemberInfo{}).Error
+				}
+			} else {
+				err = GroupDatabase.Model(&MemberInfo{}).Where("user_id = ?", FromUserID).Update("user_id", IntoUserID).Error
+			}
+		}
+		_CloseDatabase(GroupDatabase)
+		if err != nil {
+			return err
+		}
+
+		if err := IntoUserDatabase.Where(MemberOf{GroupID: memberOf.GroupID}).Attrs(MemberOf{Permissions: memberOf.Permissions}).FirstOrCreate(&MemberOf{}).Error; err != nil {
+			return err
+		}
+		if err := FromUserDatabase.Where("group_id = ?", memberOf.GroupID).Delete(&MemberOf{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// @title         _MergeUserIDs
+// @description   把表中FromUserID的记录改为IntoUserID,UniqueColumn不为空时先删除与IntoUserID已有记录冲突的行
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         Database                              *gorm.DB            "数据库(可以是事务)"
+// @param         Model                                 interface{}         "gorm对象"
+// @param         UniqueColumn                          string              "与user_id组成唯一约束的列,没有时为空"
+// @param         FromUserID                            uint                "被合并(删除)的用户ID"
+// @param         IntoUserID                            uint                "保留的用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _MergeUserIDs(Database *gorm.DB, Model interface{}, UniqueColumn string, FromUserID uint, IntoUserID uint) error {
+	if UniqueColumn != "" {
+		kept := Database.Model(Model).Select(UniqueColumn).Where("user_id = ?", IntoUserID)
+		if err := Database.Where("user_id = ? AND "+UniqueColumn+" IN (?)", FromUserID, kept).Delete(Model).Error; err != nil {
+			return err
+		}
+	}
+	return Database.Model(Model).Where("user_id = ?", FromUserID).Update("user_id", IntoUserID).Error
+}
+
+// @title         _MergeGroupRecords
+// @description   把组织数据库及其所有会议数据库中被合并用户的申请、签到、考勤修改、点名等记录改为保留的用户,两人都有的以保留的用户为准
+// @auth          DataEraserC                                   (2026/10/17   15:00)
+// @param         GlobalPath                            string              "指定数据存放在什么地方"
+// @param         GroupDatabase                         *gorm.DB            "组织数据库"
+// @param         GroupID                               uint                "组织ID"
+// @param         FromUserID                            uint                "被合并(删除)的用户ID"
+// @param         IntoUserID                            uint                "保留的用户ID"
+// @return        err                                   error               "可能存在的错误"
+func _MergeGroupRecords(GlobalPath string, GroupDatabase *gorm.DB, GroupID uint, FromUserID uint, IntoUserID uint) error {
+	err := GroupDatabase.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&JoinRequest{}, &LeaveRequest{}, &AttendanceAppeal{}, &MemberHistory{}} {
+			if err := _MergeUserIDs(tx, model, "", FromUserID, IntoUserID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 请假附件按申请ID保存,不需要移动
+
+	var meetingIDs []uint
+	if err := GroupDatabase.Model(&MeetingInfo{}).Pluck("id", &meetingIDs).Error; err != nil {
+		return err
+	}
+	for _, meetingID := range meetingIDs {
+		if _, err := os.Stat(fmt.Sprintf("%s/group/%d/meeting/%d/database.db", GlobalPath, GroupID, meetingID)); os.IsNotExist(err) {
+			continue
+		}
+		MeetingDatabase, err := InitMeeting(GlobalPath, GroupID, meetingID, true)
+		if err != nil {
+			return err
+		}
+		err = MeetingDatabase.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&MettingParticipants{}).Where("user_id = ?", IntoUserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				if err := tx.Where("user_id = ?", FromUserID).Delete(&MettingParticipants{}).Error; err != nil {
+					return err
+				}
+			}
+			for _, item := range []struct {
+				Model        interface{}
+				UniqueColumn string
+			}{
+				{&MettingParticipants{}, ""},
+				{&SignatureBook{}, "sign_id"},
+				{&PINAttempt{}, "sign_id"},
+				{&AttendanceOverride{}, "sign_id"},
+				{&AttendanceAudit{}, ""},
+				{&RollCall{}, ""},
+			} {
+				if err := _MergeUserIDs(tx, item.Model, item.UniqueColumn, FromUserID, IntoUserID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		_CloseDatabase(MeetingDatabase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// @Title       wechat.go
+// @Description 放置调用微信接口(jscode2session等)的客户端
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WXClient 微信登陆凭证校验客户端接口,CI中可以替换成指向本地假jscode2session服务器的实现
+type WXClient interface {
+	// Code2Session 用wx.login()获得的code换取OpenID及SessionKey
+	Code2Session(code string) (*WXLoginResp, error)
+}
+
+// HTTPWXClient 通过HTTP请求微信服务器的WXClient实现
+type HTTPWXClient struct {
+	// BaseURL 微信接口地址,默认https://api.weixin.qq.com,不带结尾的/
+	BaseURL   string
+	AppID     string
+	AppSecret string
+	Client    *http.Client
+}
+
+// @title         NewHTTPWXClient
+// @description   创建HTTPWXClient
+// @auth          DataEraserC               (2026/10/17   15:00)
+// @param         BaseURL           string              "微信接口地址"
+// @param         AppID             string              "微信小程序AppID"
+// @param         AppSecret         string              "微信小程序AppSecret"
+// @param         Timeout           time.Duration       "单次请求超时时间"
+// @return        client            *HTTPWXClient       "微信接口客户端"
+func NewHTTPWXClient(BaseURL string, AppID string, AppSecret string, Timeout time.Duration) *HTTPWXClient {
+	return &HTTPWXClient{
+		BaseURL:   strings.TrimRight(BaseURL, "/"),
+		AppID:     AppID,
+		AppSecret: AppSecret,
+		Client:    &http.Client{Timeout: Timeout},
+	}
+}
+
+// @title         Code2Session
+// @description   调用jscode2session处理微信登陆
+// @auth          DataEraserC               (2026/10/17   15:00)
+// @param         code              string              "微信小程序前端获得的jscode"
+// @return        wxResp            *WXLoginResp        "微信登陆返回值json对象"
+// @return        err               error               "可能存在的错误"
+func (client *HTTPWXClient) Code2Session(code string) (*WXLoginResp, error) {
+	// 合成url, 这里的appId和secret是在微信公众平台上获取的
+	query := url.Values{}
+	query.Set("appid", client.AppID)
+	query.Set("secret", client.AppSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	// 创建http get请求
+	resp, err := client.Client.Get(client.BaseURL + "/sns/jscode2session?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jscode2session返回HTTP状态码%d", resp.StatusCode)
+	}
+
+	// 解析http请求中body 数据到我们定义的结构体中
+	wxResp := WXLoginResp{}
+	decoder := json.NewDecoder(resp.Body)
+	if err := decoder.Decode(&wxResp); err != nil {
+		return nil, err
+	}
+
+	// 判断微信接口返回的是否是一个异常情况
+	if wxResp.ErrCode != 0 {
+		return nil, fmt.Errorf("ErrCode:%d  ErrMsg:%s", wxResp.ErrCode, wxResp.ErrMsg)
+	}
+	if wxResp.OpenId == "" {
+		return nil, fmt.Errorf("jscode2session未返回openid")
+	}
+
+	return &wxResp, nil
+}
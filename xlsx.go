@@ -0,0 +1,380 @@
+// @Title       xlsx.go
+// @Description 放置只有一个工作表、逐行写出的xlsx编写器(导出考勤表时不必把整个表格放在内存中)以及读取第一个工作表的xlsx读取器(导入名单)
+// @Author      DataEraserC
+// @Update      DataEraserC  (2026/10/17   15:00)
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsx除工作表外的固定部分,键为zip中的路径
+var xlsxStaticParts = []struct {
+	Name    string
+	Content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+// XLSXWriter 逐行写出只有一个工作表的xlsx,所有单元格都是文本
+type XLSXWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+// @title         NewXLSXWriter
+// @description   写出xlsx的固定部分并开始写工作表
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Writer           io.Writer           "输出"
+// @param         SheetName        string              "工作表名称"
+// @return        writer           *XLSXWriter         "xlsx编写器,写完后必须Close"
+// @return        err              error               "可能存在的错误"
+func NewXLSXWriter(Writer io.Writer, SheetName string) (*XLSXWriter, error) {
+	archive := zip.NewWriter(Writer)
+	for _, part := range xlsxStaticParts {
+		entry, err := archive.Create(part.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(entry, part.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	workbook, err := archive.Create("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	var name strings.Builder
+	xml.EscapeText(&name, []byte(SheetName))
+	if _, err := fmt.Fprintf(workbook, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`+
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`, name.String()); err != nil {
+		return nil, err
+	}
+
+	entry, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(entry)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return &XLSXWriter{zip: archive, sheet: sheet}, nil
+}
+
+// @title         xlsxColumn
+// @description   把从0开始的列号转换为A、B、...、Z、AA形式的列名
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Index            int                 "列号"
+// @return        column           string              "列名"
+func xlsxColumn(Index int) string {
+	column := ""
+	for Index++; Index > 0; Index = (Index - 1) / 26 {
+		column = string(rune('A'+(Index-1)%26)) + column
+	}
+	return column
+}
+
+// @title         WriteRow
+// @description   写出一行,空字符串写成空单元格
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Cells            []string            "单元格内容"
+// @return        err              error               "可能存在的错误"
+func (writer *XLSXWriter) WriteRow(Cells []string) error {
+	writer.rows++
+	row := strconv.Itoa(writer.rows)
+	writer.sheet.WriteString(`<row r="` + row + `">`)
+	for i, cell := range Cells {
+		if cell == "" {
+			continue
+		}
+		writer.sheet.WriteString(`<c r="` + xlsxColumn(i) + row + `" t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(writer.sheet, []byte(cell)); err != nil {
+			return err
+		}
+		writer.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := writer.sheet.WriteString(`</row>`)
+	return err
+}
+
+// @title         Close
+// @description   结束工作表并写出zip目录,不会关闭底层的输出
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        err              error               "可能存在的错误"
+func (writer *XLSXWriter) Close() error {
+	writer.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := writer.sheet.Flush(); err != nil {
+		return err
+	}
+	return writer.zip.Close()
+}
+
+// xlsxText 共享字符串或内联字符串,带格式的文字分为多段
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// @title         String
+// @description   拼接出完整的文字,忽略注音
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @return        text             string              "文字"
+func (text xlsxText) String() string {
+	if len(text.Runs) == 0 {
+		return text.T
+	}
+	var builder strings.Builder
+	for _, run := range text.Runs {
+		builder.WriteString(run.T)
+	}
+	return builder.String()
+}
+
+// xlsxMaxColumns xlsx最多的列数(A到XFD)
+const xlsxMaxColumns = 16384
+
+// xlsxRow 工作表中的一行
+type xlsxRow struct {
+	R     int `xml:"r,attr"`
+	Cells []struct {
+		R  string    `xml:"r,attr"`
+		T  string    `xml:"t,attr"`
+		V  string    `xml:"v"`
+		IS *xlsxText `xml:"is"`
+	} `xml:"c"`
+}
+
+// @title         xlsxColumnIndex
+// @description   从A1形式的单元格位置中取出从0开始的列号
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Ref              string              "单元格位置"
+// @return        index            int                 "列号,没有列名时为-1"
+func xlsxColumnIndex(Ref string) int {
+	index := 0
+	for _, char := range Ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		index = index*26 + int(char-'A'+1)
+	}
+	return index - 1
+}
+
+// @title         _OpenXLSXPart
+// @description   打开xlsx中的一个部分
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Archive          *zip.Reader         "xlsx文件"
+// @param         Name             string              "zip中的路径"
+// @return        reader           io.ReadCloser       "内容,不存在时为nil"
+// @return        err              error               "可能存在的错误"
+func _OpenXLSXPart(Archive *zip.Reader, Name string) (io.ReadCloser, error) {
+	for _, file := range Archive.File {
+		if file.Name == Name {
+			return file.Open()
+		}
+	}
+	return nil, nil
+}
+
+// @title         _XLSXFirstSheet
+// @description   从workbook.xml及其关系中找出第一个工作表的路径
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Archive          *zip.Reader         "xlsx文件"
+// @return        name             string              "第一个工作表在zip中的路径"
+// @return        err              error               "可能存在的错误"
+func _XLSXFirstSheet(Archive *zip.Reader) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var relationships struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	for _, part := range []struct {
+		Name   string
+		Target interface{}
+	}{{"xl/workbook.xml", &workbook}, {"xl/_rels/workbook.xml.rels", &relationships}} {
+		reader, err := _OpenXLSXPart(Archive, part.Name)
+		if err != nil {
+			return "", err
+		}
+		if reader == nil {
+			return "", errors.New("不是xlsx文件")
+		}
+		err = xml.NewDecoder(reader).Decode(part.Target)
+		reader.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("xlsx文件中没有工作表")
+	}
+	for _, item := range relationships.Items {
+		if item.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(item.Target, "/") {
+			return strings.TrimPrefix(item.Target, "/"), nil
+		}
+		return path.Join("xl", item.Target), nil
+	}
+	return "", errors.New("找不到第一个工作表")
+}
+
+// @title         ReadXLSXRows
+// @description   读取xlsx第一个工作表中的所有单元格,数字及日期按文件中保存的原始值返回
+// @auth          DataEraserC              (2026/10/17   15:00)
+// @param         Reader           io.ReaderAt         "xlsx文件内容"
+// @param         Size             int64               "文件大小"
+// @param         MaxRows          int                 "最多读取多少行,超过时返回错误"
+// @return        rows             [][]string          "按行号排列的单元格,rows[0]为第1行,空行为nil"
+// @return        err              error               "可能存在的错误"
+func ReadXLSXRows(Reader io.ReaderAt, Size int64, MaxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(Reader, Size)
+	if err != nil {
+		return nil, errors.New("不是xlsx文件")
+	}
+	sheetName, err := _XLSXFirstSheet(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var sharedStrings []string
+	reader, err := _OpenXLSXPart(archive, "xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	if reader != nil {
+		decoder := xml.NewDecoder(reader)
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				reader.Close()
+				return nil, err
+			}
+			if start, ok := token.(xml.StartElement); ok && start.Name.Local == "si" {
+				var text xlsxText
+				if err := decoder.DecodeElement(&text, &start); err != nil {
+					reader.Close()
+					return nil, err
+				}
+				sharedStrings = append(sharedStrings, text.String())
+			}
+		}
+		reader.Close()
+	}
+
+	reader, err = _OpenXLSXPart(archive, sheetName)
+	if err != nil {
+		return nil, err
+	}
+	if reader == nil {
+		return nil, errors.New("找不到第一个工作表")
+	}
+	defer reader.Close()
+	var rows [][]string
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row xlsxRow
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return nil, err
+		}
+		if row.R == 0 {
+			row.R = len(rows) + 1
+		}
+		// 行号必须递增,否则后面的行会覆盖前面的行
+		if row.R <= len(rows) {
+			return nil, fmt.Errorf("行号%d不合法", row.R)
+		}
+		if row.R > MaxRows {
+			return nil, fmt.Errorf("最多只能有%d行", MaxRows)
+		}
+		for len(rows) < row.R {
+			rows = append(rows, nil)
+		}
+
+		cells := []string{}
+		for _, cell := range row.Cells {
+			column := xlsxColumnIndex(cell.R)
+			if column < 0 {
+				column = len(cells)
+			} else if column >= xlsxMaxColumns {
+				return nil, fmt.Errorf("单元格%s超出了xlsx的列数", cell.R)
+			}
+			value := cell.V
+			switch cell.T {
+			case "s":
+				index, err := strconv.Atoi(cell.V)
+				if err != nil || index < 0 || index >= len(sharedStrings) {
+					return nil, fmt.Errorf("单元格%s引用了不存在的共享字符串", cell.R)
+				}
+				value = sharedStrings[index]
+			case "inlineStr":
+				if cell.IS != nil {
+					value = cell.IS.String()
+				}
+			}
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			cells[column] = value
+		}
+		rows[row.R-1] = cells
+	}
+	return rows, nil
+}